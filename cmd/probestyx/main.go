@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/devatlogstyx/probestyx/internal/auth"
 	"github.com/devatlogstyx/probestyx/internal/config"
 	"github.com/devatlogstyx/probestyx/internal/handlers"
+	"github.com/devatlogstyx/probestyx/internal/metrics"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,6 +28,8 @@ var version = "dev" // Will be overridden during build
 func main() {
 	// Add version flag
 	versionFlag := flag.Bool("version", false, "Print version and exit")
+	selfTestFlag := flag.Bool("selftest", false, "Run each system collector once, report failures and exit")
+	serviceFlag := flag.String("service", "", "Manage the Windows service: install, uninstall, start or stop")
 	flag.Parse()
 
 	if *versionFlag {
@@ -31,14 +43,164 @@ func main() {
 		configFile = args[0]
 	}
 
+	// A service has no console; log next to the config file instead
+	if isWindowsService() {
+		redirectServiceLog(configFile)
+	}
+
+	if *serviceFlag != "" {
+		if err := controlService(*serviceFlag, configFile); err != nil {
+			log.Fatalf("Service %s failed: %v", *serviceFlag, err)
+		}
+		return
+	}
+
+	cfg, info, err := loadConfig(configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Diagnose which system collectors work in this environment
+	if *selfTestFlag {
+		metrics.Init(cfg)
+		if !metrics.SelfTest(os.Stdout) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Initialize handlers with config
+	handlers.Init(cfg, version)
+	handlers.SetConfigInfo(info.mtime, info.hash)
+
+	// Under the Windows service manager stop requests arrive through the
+	// service control handler rather than signals
+	if isWindowsService() {
+		if err := runService(func(stop <-chan struct{}) { serve(cfg, configFile, stop) }); err != nil {
+			log.Fatalf("Service error: %v", err)
+		}
+		return
+	}
+
+	// Wait for shutdown signal
+	stop := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		close(stop)
+	}()
+	serve(cfg, configFile, stop)
+}
+
+// serve runs the HTTP server until stop is closed, then shuts down gracefully
+func serve(cfg *config.Config, configFile string, stop <-chan struct{}) {
+	// With an admin port, the main listener only answers /health so it can
+	// face a load balancer while metrics stay on the admin address
+	public := http.NewServeMux()
+	public.HandleFunc("/health", handlers.HealthHandler)
+	admin := public
+	if cfg.Server.AdminPort != 0 {
+		admin = http.NewServeMux()
+		admin.HandleFunc("/health", handlers.HealthHandler)
+	}
+	admin.HandleFunc("/metrics", handlers.MetricsHandler)
+	admin.HandleFunc("/refresh", handlers.RefreshHandler)
+
+	servers := []*http.Server{{Addr: net.JoinHostPort(cfg.Server.BindAddress, strconv.Itoa(cfg.Server.Port)), Handler: public}}
+	if cfg.Server.AdminPort != 0 {
+		adminBind := cfg.Server.AdminBindAddress
+		if adminBind == "" {
+			adminBind = "127.0.0.1"
+		}
+		servers = append(servers, &http.Server{Addr: net.JoinHostPort(adminBind, strconv.Itoa(cfg.Server.AdminPort)), Handler: admin})
+		log.Printf("Probestyx starting on %s (health only), metrics on %s", servers[0].Addr, servers[1].Addr)
+	} else {
+		log.Printf("Probestyx starting on %s", servers[0].Addr)
+	}
+	if secrets := auth.Secrets(); len(secrets) == 1 {
+		log.Printf("Authentication enabled with secret key")
+	} else if len(secrets) > 1 {
+		log.Printf("Authentication enabled with %d secret keys", len(secrets))
+	}
+	if cfg.Server.BearerToken != "" {
+		log.Printf("Authentication enabled with bearer token")
+	}
+	if cfg.Server.JWT != nil {
+		log.Printf("Authentication enabled with JWT")
+	}
+	if !auth.Enabled() {
+		log.Printf("Running without authentication (no secret key, bearer token or JWT configured)")
+	}
+
+	for _, server := range servers {
+		go func(server *http.Server) {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Server error on %s: %v", server.Addr, err)
+			}
+		}(server)
+	}
+
+	// Reload the config on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloadConfig(configFile, cfg.Server)
+		}
+	}()
+
+	<-stop
+
+	log.Printf("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("HTTP shutdown error on %s: %v", server.Addr, err)
+		}
+	}
+	if err := metrics.Shutdown(ctx); err != nil {
+		log.Printf("Metrics shutdown error: %v", err)
+	}
+}
+
+// Modification time and short hash of a loaded config file
+type configFileInfo struct {
+	mtime time.Time
+	hash  string
+}
+
+// loadConfig reads, parses and validates the config file
+func loadConfig(configFile string) (*config.Config, configFileInfo, error) {
+	var info configFileInfo
+
 	data, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Fatalf("Failed to read config: %v", err)
+		return nil, info, fmt.Errorf("reading config: %v", err)
+	}
+
+	// Fingerprint the loaded file so deploys can be verified from monitoring
+	if stat, err := os.Stat(configFile); err == nil {
+		info.mtime = stat.ModTime()
 	}
+	sum := sha256.Sum256(data)
+	info.hash = hex.EncodeToString(sum[:])[:12]
 
 	var cfg config.Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		log.Fatalf("Failed to parse config: %v", err)
+		return nil, info, fmt.Errorf("parsing config: %v", err)
+	}
+
+	// Expand scraper templates into regular scrapers
+	if err := cfg.ExpandTemplates(); err != nil {
+		return nil, info, fmt.Errorf("expanding scraper templates: %v", err)
+	}
+
+	// Skip scrapers that don't apply to this host
+	if err := cfg.ApplyConditions(); err != nil {
+		return nil, info, fmt.Errorf("evaluating scraper conditions: %v", err)
 	}
 
 	// Validate config
@@ -46,19 +208,31 @@ func main() {
 		cfg.Server.Port = 9100
 	}
 
-	// Initialize handlers with config
-	handlers.Init(&cfg)
+	return &cfg, info, nil
+}
 
-	// Start server
-	http.HandleFunc("/metrics", handlers.MetricsHandler)
-	http.HandleFunc("/health", handlers.HealthHandler)
+// reloadConfig swaps in the config file's current contents, keeping the
+// running config if the new one is invalid
+func reloadConfig(configFile string, listening config.ServerConfig) {
+	log.Printf("SIGHUP received, reloading config from %s", configFile)
 
-	addr := fmt.Sprintf(":%d", cfg.Server.Port)
-	log.Printf("Probestyx starting on %s", addr)
-	if cfg.Server.Secret != "" {
-		log.Printf("Authentication enabled with secret key")
-	} else {
-		log.Printf("Running without authentication (no secret key configured)")
+	cfg, info, err := loadConfig(configFile)
+	if err != nil {
+		log.Printf("WARN: Config reload failed, keeping the current config: %v", err)
+		return
 	}
-	log.Fatal(http.ListenAndServe(addr, nil))
-}
\ No newline at end of file
+
+	// The listeners are already bound
+	if cfg.Server.Port != listening.Port || cfg.Server.BindAddress != listening.BindAddress ||
+		cfg.Server.AdminPort != listening.AdminPort || cfg.Server.AdminBindAddress != listening.AdminBindAddress {
+		log.Printf("WARN: server listen addresses changed; restart to apply, still listening on the old ones")
+		cfg.Server.Port = listening.Port
+		cfg.Server.BindAddress = listening.BindAddress
+		cfg.Server.AdminPort = listening.AdminPort
+		cfg.Server.AdminBindAddress = listening.AdminBindAddress
+	}
+
+	handlers.Reload(cfg)
+	handlers.SetConfigInfo(info.mtime, info.hash)
+	log.Printf("Config reloaded (hash %s)", info.hash)
+}
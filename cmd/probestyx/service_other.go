@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// Service management only exists on Windows; elsewhere probestyx runs under
+// systemd, launchd or a console and stops on SIGINT/SIGTERM.
+
+func isWindowsService() bool {
+	return false
+}
+
+func redirectServiceLog(configFile string) {}
+
+func runService(run func(stop <-chan struct{})) error {
+	return fmt.Errorf("not supported on this platform")
+}
+
+func controlService(action, configFile string) error {
+	return fmt.Errorf("-service is only supported on Windows")
+}
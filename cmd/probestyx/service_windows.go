@@ -0,0 +1,151 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	serviceName        = "Probestyx"
+	serviceDisplayName = "Probestyx metrics exporter"
+)
+
+func isWindowsService() bool {
+	inService, err := svc.IsWindowsService()
+	return err == nil && inService
+}
+
+// redirectServiceLog appends log output to probestyx.log beside the config
+func redirectServiceLog(configFile string) {
+	f, err := os.OpenFile(filepath.Join(filepath.Dir(configFile), "probestyx.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	log.SetOutput(f)
+}
+
+// probestyxService adapts serve to the service control manager
+type probestyxService struct {
+	run func(stop <-chan struct{})
+}
+
+func (s *probestyxService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.run(stop)
+		close(done)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				close(stop)
+				<-done
+				return false, 0
+			}
+		case <-done:
+			return false, 0
+		}
+	}
+}
+
+func runService(run func(stop <-chan struct{})) error {
+	return svc.Run(serviceName, &probestyxService{run: run})
+}
+
+// controlService installs, uninstalls, starts or stops the service
+func controlService(action, configFile string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if action == "install" {
+		return installService(m, configFile)
+	}
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %v", serviceName, err)
+	}
+	defer s.Close()
+
+	switch action {
+	case "uninstall":
+		return s.Delete()
+	case "start":
+		return s.Start()
+	case "stop":
+		return stopService(s)
+	}
+	return fmt.Errorf("unknown action %q (use install, uninstall, start or stop)", action)
+}
+
+func installService(m *mgr.Mgr, configFile string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// The service starts in the system directory, so pin the config path
+	config, err := filepath.Abs(configFile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(config); err != nil {
+		return fmt.Errorf("config file: %v", err)
+	}
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: serviceDisplayName,
+		StartType:   mgr.StartAutomatic,
+	}, config)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	log.Printf("Installed service %s with config %s", serviceName, config)
+	return nil
+}
+
+func stopService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(15 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
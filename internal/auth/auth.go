@@ -3,9 +3,12 @@ package auth
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/devatlogstyx/probestyx/internal/config"
@@ -15,33 +18,139 @@ var cfg *config.Config
 
 func Init(c *config.Config) {
 	cfg = c
+	initTrustedProxies()
+	initJWT()
 }
 
-func ValidateSignature(r *http.Request) bool {
+// Enabled reports whether any authentication mechanism is configured
+func Enabled() bool {
+	return len(Secrets()) > 0 || cfg.Server.BearerToken != "" || cfg.Server.JWT != nil
+}
+
+// Secrets returns every accepted HMAC secret: secret followed by secrets
+func Secrets() []string {
+	var secrets []string
+	if cfg.Server.Secret != "" {
+		secrets = append(secrets, cfg.Server.Secret)
+	}
+	for _, secret := range cfg.Server.Secrets {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// Reasons a request failed authentication. They name the failed check
+// without revealing anything about the expected credentials, so they are
+// safe to return to clients.
+var (
+	ErrMissingCredentials = errors.New("missing_credentials")
+	ErrMalformedTimestamp = errors.New("malformed_timestamp")
+	ErrTimestampExpired   = errors.New("timestamp_expired")
+	ErrInvalidSignature   = errors.New("invalid_signature")
+	ErrInvalidToken       = errors.New("invalid_token")
+	ErrTokenExpired       = errors.New("token_expired")
+)
+
+// Challenge returns the WWW-Authenticate value for a request rejected with
+// err. The error attribute uses the RFC 6750 codes so standard clients can
+// parse it: none when no credentials were sent, invalid_request for a
+// malformed one and invalid_token for rejected credentials. The specific
+// reason goes in error_description.
+func Challenge(err error) string {
+	const scheme = `Bearer realm="probestyx"`
+	code := "invalid_token"
+	switch err {
+	case ErrMissingCredentials:
+		return scheme
+	case ErrMalformedTimestamp:
+		code = "invalid_request"
+	}
+	return scheme + `, error="` + code + `", error_description="` + err.Error() + `"`
+}
+
+// Authenticate returns nil if r satisfies at least one configured
+// mechanism. Otherwise it returns the reason from the last mechanism the
+// client sent credentials for, or ErrMissingCredentials if it sent none.
+func Authenticate(r *http.Request) error {
+	reason := ErrMissingCredentials
+	passed := func(err error) bool {
+		if err != nil && err != ErrMissingCredentials {
+			reason = err
+		}
+		return err == nil
+	}
+
+	if cfg.Server.BearerToken != "" && passed(ValidateBearer(r)) {
+		return nil
+	}
+	if len(Secrets()) > 0 && passed(ValidateSignature(r)) {
+		return nil
+	}
+	if cfg.Server.JWT != nil && passed(ValidateJWT(r)) {
+		return nil
+	}
+	return reason
+}
+
+// ValidateBearer checks the Authorization header against the configured token
+func ValidateBearer(r *http.Request) error {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return ErrMissingCredentials
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Server.BearerToken)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// ValidateSignature checks the X-Timestamp and X-Signature headers. A
+// signature made with any configured secret is accepted, so secrets can be
+// rotated without a flag day.
+func ValidateSignature(r *http.Request) error {
 	signature := r.Header.Get("X-Signature")
 	timestamp := r.Header.Get("X-Timestamp")
 
 	if signature == "" || timestamp == "" {
-		return false
+		return ErrMissingCredentials
 	}
 
 	// Check timestamp is within 5 minutes
 	ts, err := strconv.ParseInt(timestamp, 10, 64)
 	if err != nil {
-		return false
+		return ErrMalformedTimestamp
 	}
 
 	now := time.Now().Unix()
 	if abs(now-ts) > 300 {
-		return false
+		return ErrTimestampExpired
 	}
 
-	// Verify HMAC
-	mac := hmac.New(sha256.New, []byte(cfg.Server.Secret))
-	mac.Write([]byte(timestamp))
-	expected := hex.EncodeToString(mac.Sum(nil))
+	// Verify HMAC against every secret so the time taken doesn't reveal
+	// which one matched
+	valid := false
+	for _, secret := range Secrets() {
+		if hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp))) {
+			valid = true
+		}
+	}
 
-	return hmac.Equal([]byte(signature), []byte(expected))
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of timestamp keyed by secret.
+// This is the value expected in the X-Signature header.
+func Sign(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 func abs(n int64) int64 {
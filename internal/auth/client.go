@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SignRequest sets X-Timestamp and X-Signature on req for the given secret.
+func SignRequest(req *http.Request, secret string) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature", Sign(secret, timestamp))
+}
+
+// Client wraps an http.Client and signs every outgoing request, so
+// integrators don't have to reimplement the signing scheme.
+type Client struct {
+	HTTPClient *http.Client
+	Secret     string
+}
+
+// NewClient returns a signing client. A nil httpClient uses http.DefaultClient.
+func NewClient(secret string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{HTTPClient: httpClient, Secret: secret}
+}
+
+// Do signs req and sends it.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	SignRequest(req, c.Secret)
+	return c.HTTPClient.Do(req)
+}
+
+// Get issues a signed GET to url.
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
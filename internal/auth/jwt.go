@@ -0,0 +1,418 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers crypto.SHA256
+	_ "crypto/sha512" // registers crypto.SHA384 and crypto.SHA512
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Allowed clock skew when checking exp and nbf
+const jwtLeeway = 30 * time.Second
+
+// Minimum time between JWKS fetches triggered by an unknown key ID
+const jwksRefreshInterval = time.Minute
+
+// Verification keys for RS*/ES* tokens: the configured public_key, and
+// the JWKS keys by kid. jwksKeys is replaced wholesale on every fetch so
+// a key dropped from the set stops being trusted.
+var (
+	jwtPublicKey crypto.PublicKey
+	jwksKeys     map[string]crypto.PublicKey
+	jwksFetched  time.Time
+	jwtKeysMu    sync.RWMutex
+
+	// Serialises JWKS fetches; held without jwtKeysMu so auth checks
+	// keep using the current keys while a fetch is in flight
+	jwksFetchMu sync.Mutex
+)
+
+func initJWT() {
+	var publicKey crypto.PublicKey
+	var keys map[string]crypto.PublicKey
+
+	jwt := cfg.Server.JWT
+	if jwt != nil {
+		if jwt.SigningKey == "" && jwt.PublicKey == "" && jwt.JWKSURL == "" {
+			log.Printf("WARN: server.jwt has no signing_key, public_key or jwks_url; every token will be rejected")
+		}
+
+		if jwt.PublicKey != "" {
+			key, err := loadPublicKey(jwt.PublicKey)
+			if err != nil {
+				log.Printf("WARN: Failed to load JWT public key: %v", err)
+			} else {
+				publicKey = key
+			}
+		}
+
+		if jwt.JWKSURL != "" {
+			var err error
+			if keys, err = fetchJWKS(jwt.JWKSURL); err != nil {
+				log.Printf("WARN: Failed to fetch JWKS from %s: %v", jwt.JWKSURL, err)
+			}
+		}
+	}
+
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	jwtPublicKey = publicKey
+	jwksKeys = keys
+	jwksFetched = time.Now()
+}
+
+// ValidateJWT checks a `Authorization: Bearer <jwt>` header against the
+// configured server.jwt block: signature, expiry, and issuer and audience
+// when set
+func ValidateJWT(r *http.Request) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return ErrMissingCredentials
+	}
+
+	err := verifyJWT(token, time.Now())
+	if err == nil {
+		return nil
+	}
+	log.Printf("JWT rejected from %s: %v", ClientIP(r), err)
+	if errors.Is(err, errJWTExpired) {
+		return ErrTokenExpired
+	}
+	return ErrInvalidToken
+}
+
+var errJWTExpired = errors.New("token expired")
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"` // string or array
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+}
+
+func verifyJWT(token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("signature: %v", err)
+	}
+	if err := verifyJWTSignature(header, parts[0]+"."+parts[1], signature); err != nil {
+		return err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("claims: %v", err)
+	}
+
+	// Tokens are expected to be short-lived, so exp is required
+	if claims.ExpiresAt == nil {
+		return errors.New("missing exp claim")
+	}
+	if now.After(unixTime(*claims.ExpiresAt).Add(jwtLeeway)) {
+		return errJWTExpired
+	}
+	if claims.NotBefore != nil && now.Add(jwtLeeway).Before(unixTime(*claims.NotBefore)) {
+		return errors.New("token not valid yet")
+	}
+
+	jwt := cfg.Server.JWT
+	if jwt.Issuer != "" && claims.Issuer != jwt.Issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if jwt.Audience != "" && !hasAudience(claims.Audience, jwt.Audience) {
+		return errors.New("audience mismatch")
+	}
+	return nil
+}
+
+func verifyJWTSignature(header jwtHeader, signed string, signature []byte) error {
+	// Includes "none", which must never be accepted
+	if len(header.Alg) != 5 {
+		return fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+	family := header.Alg[:2]
+
+	var cryptoHash crypto.Hash
+	switch header.Alg[2:] {
+	case "256":
+		cryptoHash = crypto.SHA256
+	case "384":
+		cryptoHash = crypto.SHA384
+	case "512":
+		cryptoHash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+
+	switch family {
+	case "HS":
+		if cfg.Server.JWT.SigningKey == "" {
+			return fmt.Errorf("alg %s requires signing_key", header.Alg)
+		}
+		mac := hmac.New(cryptoHash.New, []byte(cfg.Server.JWT.SigningKey))
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case "RS", "ES":
+		key, err := lookupJWTKey(header.Kid)
+		if err != nil {
+			return err
+		}
+		h := cryptoHash.New()
+		h.Write([]byte(signed))
+		digest := h.Sum(nil)
+
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if family != "RS" {
+				break
+			}
+			if rsa.VerifyPKCS1v15(key, cryptoHash, digest, signature) != nil {
+				return errors.New("invalid signature")
+			}
+			return nil
+		case *ecdsa.PublicKey:
+			if family != "ES" {
+				break
+			}
+			// JWS ECDSA signatures are the fixed-size concatenation r || s
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*size {
+				return errors.New("invalid signature")
+			}
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if !ecdsa.Verify(key, digest, r, s) {
+				return errors.New("invalid signature")
+			}
+			return nil
+		}
+		return fmt.Errorf("key does not match alg %s", header.Alg)
+	}
+	return fmt.Errorf("unsupported alg %q", header.Alg)
+}
+
+// lookupJWTKey finds the verification key for kid, refetching the JWKS
+// (at most once per jwksRefreshInterval) when the kid is unknown so key
+// rotation doesn't need a restart
+func lookupJWTKey(kid string) (crypto.PublicKey, error) {
+	if key, ok := cachedJWTKey(kid); ok {
+		return key, nil
+	}
+
+	if url := cfg.Server.JWT.JWKSURL; url != "" {
+		refreshJWKS(url)
+		if key, ok := cachedJWTKey(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no key for kid %q", kid)
+}
+
+// cachedJWTKey looks kid up in the JWKS, falling back to public_key
+func cachedJWTKey(kid string) (crypto.PublicKey, bool) {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	if key, ok := jwksKeys[kid]; ok {
+		return key, true
+	}
+	return jwtPublicKey, jwtPublicKey != nil
+}
+
+// refreshJWKS replaces the JWKS keys with a fresh fetch unless one was
+// made within jwksRefreshInterval. A failed fetch keeps the previous keys.
+func refreshJWKS(url string) {
+	jwksFetchMu.Lock()
+	defer jwksFetchMu.Unlock()
+
+	// Another request may have refreshed while we waited
+	jwtKeysMu.RLock()
+	stale := time.Since(jwksFetched) >= jwksRefreshInterval
+	jwtKeysMu.RUnlock()
+	if !stale {
+		return
+	}
+
+	keys, err := fetchJWKS(url)
+
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	jwksFetched = time.Now()
+	if err != nil {
+		log.Printf("WARN: Failed to refresh JWKS from %s: %v", url, err)
+		return
+	}
+	jwksKeys = keys
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchJWKS downloads the key set at url, returning its signing keys by kid
+func fetchJWKS(url string) (map[string]crypto.PublicKey, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			log.Printf("WARN: Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// loadPublicKey reads an RSA or ECDSA public key, or a certificate, from
+// a PEM file
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func hasAudience(raw json.RawMessage, want string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == want
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		for _, aud := range list {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*1e9))
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+const testSigningKey = "test-signing-key"
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+// jwksServer serves the public halves of keys as a JWKS, by kid. The key
+// set can be swapped between fetches to simulate rotation.
+type jwksServer struct {
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+}
+
+func (s *jwksServer) set(keys map[string]crypto.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range s.keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, jwk{
+				Kty: "RSA", Kid: kid, Use: "sig",
+				N: b64(key.N.Bytes()),
+				E: b64(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, jwk{
+				Kty: "EC", Kid: kid, Crv: key.Curve.Params().Name,
+				X: b64(key.X.Bytes()), Y: b64(key.Y.Bytes()),
+			})
+		}
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64(data)
+}
+
+// signJWT builds a token signed with key: a []byte HMAC secret, an RSA or
+// an ECDSA private key, or nil for an empty signature
+func signJWT(t *testing.T, header, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + b64(signature)
+}
+
+func setupJWT(t *testing.T, jwt *config.JWTConfig) {
+	t.Helper()
+	Init(&config.Config{Server: config.ServerConfig{JWT: jwt}})
+	t.Cleanup(func() { Init(&config.Config{}) })
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherECKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := &jwksServer{keys: map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey}}
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	setupJWT(t, &config.JWTConfig{
+		Issuer:     "issuer",
+		Audience:   "probestyx",
+		SigningKey: testSigningKey,
+		JWKSURL:    server.URL,
+	})
+
+	exp := float64(testNow.Add(time.Hour).Unix())
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": "issuer", "aud": "probestyx", "exp": exp}
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	hs := map[string]interface{}{"alg": "HS256"}
+	rs := map[string]interface{}{"alg": "RS256", "kid": "rsa"}
+	es := map[string]interface{}{"alg": "ES256", "kid": "ec"}
+	leeway := jwtLeeway.Seconds()
+
+	valid := signJWT(t, hs, claims(nil), []byte(testSigningKey))
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + encodeSegment(t, claims(map[string]interface{}{"iss": "attacker"})) + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"hs256", valid, true},
+		{"rs256", signJWT(t, rs, claims(nil), rsaKey), true},
+		{"es256", signJWT(t, es, claims(nil), ecKey), true},
+
+		{"alg none", signJWT(t, map[string]interface{}{"alg": "none"}, claims(nil), nil), false},
+		{"alg none uppercase", signJWT(t, map[string]interface{}{"alg": "NONE"}, claims(nil), nil), false},
+		{"hs wrong key", signJWT(t, hs, claims(nil), []byte("wrong")), false},
+		{"rs wrong key", signJWT(t, rs, claims(nil), otherRSAKey), false},
+		{"es wrong key", signJWT(t, es, claims(nil), otherECKey), false},
+		{"rs alg with ec key", signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "ec"}, claims(nil), rsaKey), false},
+		{"hs alg signed with rsa public key", signJWT(t, map[string]interface{}{"alg": "HS256", "kid": "rsa"}, claims(nil), rsaKey.PublicKey.N.Bytes()), false},
+		{"tampered payload", tampered, false},
+		{"unknown kid", signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "missing"}, claims(nil), rsaKey), false},
+		{"malformed", "not.a-token", false},
+
+		{"missing exp", signJWT(t, hs, claims(map[string]interface{}{"exp": nil}), []byte(testSigningKey)), false},
+		{"expired", signJWT(t, hs, claims(map[string]interface{}{"exp": float64(testNow.Unix()) - leeway - 1}), []byte(testSigningKey)), false},
+		{"expired within skew", signJWT(t, hs, claims(map[string]interface{}{"exp": float64(testNow.Unix()) - leeway + 1}), []byte(testSigningKey)), true},
+		{"not yet valid", signJWT(t, hs, claims(map[string]interface{}{"nbf": float64(testNow.Unix()) + leeway + 1}), []byte(testSigningKey)), false},
+		{"not yet valid within skew", signJWT(t, hs, claims(map[string]interface{}{"nbf": float64(testNow.Unix()) + leeway - 1}), []byte(testSigningKey)), true},
+
+		{"audience mismatch", signJWT(t, hs, claims(map[string]interface{}{"aud": "other"}), []byte(testSigningKey)), false},
+		{"audience in list", signJWT(t, hs, claims(map[string]interface{}{"aud": []string{"other", "probestyx"}}), []byte(testSigningKey)), true},
+		{"audience missing", signJWT(t, hs, claims(map[string]interface{}{"aud": nil}), []byte(testSigningKey)), false},
+		{"issuer mismatch", signJWT(t, hs, claims(map[string]interface{}{"iss": "other"}), []byte(testSigningKey)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyJWT(tt.token, testNow)
+			if tt.ok && err != nil {
+				t.Errorf("rejected valid token: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("accepted invalid token")
+			}
+		})
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := &jwksServer{keys: map[string]crypto.PublicKey{"old": &oldKey.PublicKey}}
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+	setupJWT(t, &config.JWTConfig{JWKSURL: server.URL})
+
+	claims := map[string]interface{}{"exp": float64(testNow.Add(time.Hour).Unix())}
+	oldToken := signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "old"}, claims, oldKey)
+	newToken := signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "new"}, claims, newKey)
+
+	if err := verifyJWT(oldToken, testNow); err != nil {
+		t.Fatalf("old key rejected before rotation: %v", err)
+	}
+
+	// The key set rotates; the unknown kid triggers a refetch once the
+	// refresh interval has passed
+	jwks.set(map[string]crypto.PublicKey{"new": &newKey.PublicKey})
+	if err := verifyJWT(newToken, testNow); err == nil {
+		t.Fatal("new key accepted before the refresh interval passed")
+	}
+	jwtKeysMu.Lock()
+	jwksFetched = time.Time{}
+	jwtKeysMu.Unlock()
+
+	if err := verifyJWT(newToken, testNow); err != nil {
+		t.Fatalf("new key rejected after rotation: %v", err)
+	}
+	if err := verifyJWT(oldToken, testNow); err == nil {
+		t.Error("key removed from the JWKS is still trusted")
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Networks whose X-Forwarded-For headers are trusted
+var trustedProxies []*net.IPNet
+
+func initTrustedProxies() {
+	trustedProxies = nil
+	for _, entry := range cfg.Server.TrustedProxies {
+		// Accept bare IPs as single-host networks
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("WARN: Ignoring invalid trusted proxy '%s': %v", entry, err)
+			continue
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the real client. X-Forwarded-For is only
+// consulted when the direct peer is a trusted proxy; the header is then
+// walked from the right, skipping trusted hops, so a client can't spoof its
+// address by prepending entries.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	peer := net.ParseIP(host)
+	if peer == nil || !isTrustedProxy(peer) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			// Malformed entry: stop trusting anything further left
+			break
+		}
+		if !isTrustedProxy(ip) {
+			return hop
+		}
+	}
+
+	return host
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+)
+
+// ConditionConfig describes host facts a scraper depends on. All set
+// fields must hold for the scraper to be enabled.
+type ConditionConfig struct {
+	FileExists string `yaml:"file_exists,omitempty"` // path that must exist
+	Hostname   string `yaml:"hostname,omitempty"`    // regex the hostname must match
+}
+
+// ApplyConditions drops scrapers whose enabled_if conditions don't hold on
+// this host. It runs once at load time.
+func (c *Config) ApplyConditions() error {
+	hostname, _ := os.Hostname()
+
+	enabled := c.Scrapers[:0]
+	for _, scraper := range c.Scrapers {
+		if scraper.EnabledIf == nil {
+			enabled = append(enabled, scraper)
+			continue
+		}
+
+		ok, reason, err := scraper.EnabledIf.holds(hostname)
+		if err != nil {
+			return fmt.Errorf("scraper %s: %v", scraper.Name, err)
+		}
+		if !ok {
+			log.Printf("Scraper %s disabled on this host: %s", scraper.Name, reason)
+			continue
+		}
+		enabled = append(enabled, scraper)
+	}
+	c.Scrapers = enabled
+
+	return nil
+}
+
+func (cond *ConditionConfig) holds(hostname string) (bool, string, error) {
+	if cond.FileExists != "" {
+		if _, err := os.Stat(cond.FileExists); err != nil {
+			return false, fmt.Sprintf("%s does not exist", cond.FileExists), nil
+		}
+	}
+
+	if cond.Hostname != "" {
+		re, err := regexp.Compile(cond.Hostname)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid enabled_if.hostname regex: %v", err)
+		}
+		if !re.MatchString(hostname) {
+			return false, fmt.Sprintf("hostname %s does not match %s", hostname, cond.Hostname), nil
+		}
+	}
+
+	return true, "", nil
+}
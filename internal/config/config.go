@@ -5,18 +5,119 @@ type Config struct {
 	Server   ServerConfig    `yaml:"server"`
 	System   SystemConfig    `yaml:"system"`
 	Scrapers []ScraperConfig `yaml:"scrapers"`
+
+	ScraperTemplates []ScraperTemplate `yaml:"scraper_templates,omitempty"`
+
+	RemoteWrite *RemoteWriteConfig `yaml:"remote_write,omitempty"` // push to a Prometheus remote-write endpoint
+	StatsD      *StatsDConfig      `yaml:"statsd,omitempty"`       // push gauges to a StatsD server over UDP
+}
+
+type StatsDConfig struct {
+	Address         string `yaml:"address"`                    // host:port
+	IntervalSeconds int    `yaml:"interval_seconds,omitempty"` // default 15
+	Prefix          string `yaml:"prefix,omitempty"`           // prepended to every name
+	MaxPacketBytes  int    `yaml:"max_packet_bytes,omitempty"` // default 1432, metrics are batched up to this size
+}
+
+type RemoteWriteConfig struct {
+	URL             string            `yaml:"url"`
+	IntervalSeconds int               `yaml:"interval_seconds,omitempty"` // default 15
+	Username        string            `yaml:"username,omitempty"`         // optional basic auth
+	Password        string            `yaml:"password,omitempty"`
+	Labels          map[string]string `yaml:"labels,omitempty"` // added to every series; instance defaults to the hostname
 }
 
 type ServerConfig struct {
-	Port   int    `yaml:"port"`
-	Secret string `yaml:"secret"`
+	Port        int    `yaml:"port"`
+	Secret      string `yaml:"secret"`
+	BearerToken string `yaml:"bearer_token"` // alternative to HMAC signing
+
+	Secrets []string `yaml:"secrets,omitempty"` // additional HMAC secrets accepted alongside secret, for rotation
+
+	JWT *JWTConfig `yaml:"jwt,omitempty"` // accept signed JWTs as bearer tokens
+
+	BindAddress      string `yaml:"bind_address"`       // listen address for port, default all interfaces
+	AdminPort        int    `yaml:"admin_port"`         // serve /metrics and /refresh here, leaving only /health on port
+	AdminBindAddress string `yaml:"admin_bind_address"` // default 127.0.0.1
+
+	TrustedProxies []string `yaml:"trusted_proxies"` // CIDRs allowed to set X-Forwarded-For
+
+	IncludeTimestamps bool   `yaml:"include_timestamps"` // wrap values with collection time
+	StrictScrapers    bool   `yaml:"strict_scrapers"`    // fail the request if any scraper fails
+	GraphitePrefix    string `yaml:"graphite_prefix"`    // path prefix for ?format=graphite
+
+	ChangedAlwaysInclude []string `yaml:"changed_always_include"` // keys kept by ?changed=true even when unchanged
+
+	RequestTimeoutSeconds float64 `yaml:"request_timeout_seconds"` // cap on /metrics collection, returns what's ready after it
+
+	LogRequests string `yaml:"log_requests"` // all (default), none, or sample:N to log one /metrics request in N
+
+	OutputEnvelope bool `yaml:"output_envelope"` // wrap JSON as {"host", "collected_at", "version", "metrics"}
+
+	MergeStrategy string `yaml:"merge_strategy"` // which scraper wins a key collision at equal priority: last (default) or first
+
+	MaxScrapesPerSecond float64 `yaml:"max_scrapes_per_second"` // global ceiling on outbound url fetches across all scrapers, 0 unlimited
+
+	ScraperStateTTLSeconds int `yaml:"scraper_state_ttl_seconds"` // drop idle state of removed scrapers, default 3600, -1 never
+
+	SelfMetrics bool `yaml:"self_metrics"` // expose probestyx's own goroutine, heap and GC stats
+	BuildInfo   bool `yaml:"build_info"`   // expose probestyx_build_info with the running version
+}
+
+// JWTConfig validates `Authorization: Bearer <jwt>` tokens. HS* tokens are
+// checked with signing_key, RS*/ES* tokens with public_key or the JWKS.
+type JWTConfig struct {
+	Issuer     string `yaml:"issuer,omitempty"`      // required iss claim
+	Audience   string `yaml:"audience,omitempty"`    // must appear in the aud claim
+	SigningKey string `yaml:"signing_key,omitempty"` // shared secret for HS256/384/512
+	PublicKey  string `yaml:"public_key,omitempty"`  // PEM public key or certificate file
+	JWKSURL    string `yaml:"jwks_url,omitempty"`    // key set fetched at startup and on unknown kid
 }
 
 type SystemConfig struct {
-    Enabled  bool     `yaml:"enabled"`
-    Name     string   `yaml:"name"`
-    CacheTTL int      `yaml:"cache_ttl"`  // Add this line
-    Metrics  []string `yaml:"metrics"`
+	Enabled            bool     `yaml:"enabled"`
+	Name               string   `yaml:"name"`
+	CacheTTL           int      `yaml:"cache_ttl"`            // Add this line
+	CacheJitterPercent int      `yaml:"cache_jitter_percent"` // randomize TTL by ±N%
+	MaxStaleSeconds    int      `yaml:"max_stale_seconds"`    // refuse to serve older metrics, 0 disables
+	RateWindowSeconds  int      `yaml:"rate_window_seconds"`  // average *_per_sec over this window, 0 uses the last collection
+	Metrics            []string `yaml:"metrics"`
+	AllMetrics         bool     `yaml:"all_metrics"`     // enable every known metric
+	ExcludeMetrics     []string `yaml:"exclude_metrics"` // removed from the enabled set
+
+	CollectionTimeoutSeconds int    `yaml:"collection_timeout_seconds"` // default 10
+	Grouped                  bool   `yaml:"grouped"`                    // nest metrics by category
+	SequentialCollection     bool   `yaml:"sequential_collection"`      // run collectors one at a time
+	Precision                *int   `yaml:"precision"`                  // decimal places, default 2
+	ByteUnit                 string `yaml:"byte_unit"`                  // bytes, kb, mb, gb, tb
+
+	AutoDiscoverDisks bool     `yaml:"auto_discover_disks"` // per-mount disk usage
+	ExcludeFSTypes    []string `yaml:"exclude_fstypes"`     // in addition to pseudo filesystems
+
+	ExcludeVirtualInterfaces bool `yaml:"exclude_virtual_interfaces"` // leave loopback, bridge and container interfaces out of network totals
+
+	ListeningPortsProcess bool `yaml:"listening_ports_process,omitempty"` // label listening_ports with the owning process name
+
+	SystemdUnits   []string `yaml:"systemd_units"`   // Linux only, queried via systemctl
+	TrackProcesses []string `yaml:"track_processes"` // process name regexes, aggregated per pattern
+
+	Derived []DerivedMetric `yaml:"derived,omitempty"`
+
+	NTPServer       string `yaml:"ntp_server,omitempty"`        // host[:port] queried for clock_offset_ms
+	NTPCacheSeconds int    `yaml:"ntp_cache_seconds,omitempty"` // default 3600
+
+	Labels    map[string]string `yaml:"labels,omitempty"`     // added to system series in Prometheus output and remote write
+	NameLabel string            `yaml:"name_label,omitempty"` // emit name as this label instead of a metric name prefix
+
+	SmoothingFactor float64 `yaml:"smoothing_factor,omitempty"` // EMA weight of each new sample for volatile gauges, 0 disables
+
+	WarmOnStart bool `yaml:"warm_on_start,omitempty"` // collect once at startup and reload so the first request hits the cache
+}
+
+// DerivedMetric is computed from already-collected system metrics
+type DerivedMetric struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
 }
 
 type ScraperConfig struct {
@@ -24,24 +125,139 @@ type ScraperConfig struct {
 	Source  SourceConfig  `yaml:"source"`
 	Metrics []MetricMap   `yaml:"metrics"`
 	Filter  *FilterConfig `yaml:"filter,omitempty"`
+
+	Sources []SubSourceConfig `yaml:"sources,omitempty"` // fetched in turn and merged, in place of source and metrics
+
+	Namespace string `yaml:"namespace,omitempty"` // nested (default), flat, or a key shared with other scrapers
+	Priority  int    `yaml:"priority,omitempty"`  // wins key collisions with lower-priority scrapers
+
+	AutoMap       bool            `yaml:"auto_map,omitempty"`       // emit every filtered key
+	NameTransform []NameTransform `yaml:"name_transform,omitempty"` // applied to auto-mapped names
+	Relabel       []RelabelConfig `yaml:"relabel,omitempty"`        // applied to every output name, in order
+	NameStyle     string          `yaml:"name_style,omitempty"`     // as-is (default), snake, camel or lower
+
+	TopK  int `yaml:"top_k,omitempty"` // keep only the N highest-value metrics
+	Limit int `yaml:"limit,omitempty"` // keep at most N metrics, by key order
+
+	OnFailureWebhook string `yaml:"on_failure_webhook,omitempty"` // POSTed on failure/recovery
+
+	FailureThreshold  int `yaml:"failure_threshold,omitempty"`  // consecutive failures before down, default 1
+	RecoveryThreshold int `yaml:"recovery_threshold,omitempty"` // consecutive successes before up again, default 1
+
+	EnabledIf *ConditionConfig `yaml:"enabled_if,omitempty"` // host facts checked at startup
+}
+
+type NameTransform struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+// RelabelConfig rewrites output series names and labels, loosely mirroring
+// Prometheus relabel_configs. Source and TargetLabel default to the metric
+// name.
+type RelabelConfig struct {
+	Source      string `yaml:"source,omitempty"`       // label to read, or __name__
+	Regex       string `yaml:"regex,omitempty"`        // fully anchored, default (.*)
+	Replacement string `yaml:"replacement,omitempty"`  // default $1
+	TargetLabel string `yaml:"target_label,omitempty"` // label to write, or __name__
+	Action      string `yaml:"action,omitempty"`       // replace (default), lowercase, uppercase, keep, drop
 }
 
 type SourceConfig struct {
-	Type    string `yaml:"type"` // url, file
-	URL     string `yaml:"url,omitempty"`
-	Path    string `yaml:"path,omitempty"`
-	Format  string `yaml:"format"` // json, prometheus, raw
-	Pattern string `yaml:"pattern,omitempty"`
+	Type        string `yaml:"type"` // url, file, fifo, snmp, ssh, sql, perfcounter
+	URL         string `yaml:"url,omitempty"`
+	FallbackURL string `yaml:"fallback_url,omitempty"` // tried when url fails
+	Retries     int    `yaml:"retries,omitempty"`      // url sources, extra attempts per URL before failing over
+	Path        string `yaml:"path,omitempty"`
+	Format      string `yaml:"format"` // json, ndjson, expvar, prometheus, raw, auto
+	Pattern     string `yaml:"pattern,omitempty"`
+
+	KeyField string `yaml:"key_field,omitempty"` // format: ndjson, index objects by this field instead of merging
+
+	UseNumber bool `yaml:"use_number,omitempty"` // format: json, keep integers exact instead of converting to float64
+
+	KeepMetadata bool `yaml:"keep_metadata,omitempty"` // format: prometheus, carry # HELP/# TYPE into ?meta=true and /metrics?format=prometheus
+
+	// Request options for url sources. url, fallback_url and body may use
+	// templates such as {{hostname}} or {{.Env.REGION}}, resolved per scrape.
+	Method      string `yaml:"method,omitempty"` // default GET, or POST when body is set
+	Body        string `yaml:"body,omitempty"`
+	ContentType string `yaml:"content_type,omitempty"` // for body, default application/json
+
+	// Capture group roles for format: raw, default key 1 and value 2
+	KeyGroup   int    `yaml:"key_group,omitempty"`
+	ValueGroup int    `yaml:"value_group,omitempty"`
+	LabelGroup int    `yaml:"label_group,omitempty"` // optional, attached as a label
+	LabelName  string `yaml:"label_name,omitempty"`  // default "label"
+	Debug      bool   `yaml:"debug,omitempty"`       // log matches and unmatched lines, label values with line="N"
+
+	MaxResponseBytes int64 `yaml:"max_response_bytes,omitempty"` // default 10MB
+
+	HeaderMetrics map[string]string `yaml:"header_metrics,omitempty"` // response header -> metric name, url sources only
+
+	// TLS options for url sources
+	ClientCert         string `yaml:"client_cert,omitempty"`
+	ClientKey          string `yaml:"client_key,omitempty"`
+	CACert             string `yaml:"ca_cert,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // dev/self-signed endpoints only
+
+	// SNMP options for snmp sources
+	SNMPTarget string            `yaml:"snmp_target,omitempty"` // host[:port], port defaults to 161
+	Community  string            `yaml:"community,omitempty"`   // default "public"
+	Version    string            `yaml:"version,omitempty"`     // 1 or 2c (default)
+	OIDs       map[string]string `yaml:"oids,omitempty"`        // OID -> metric name
+
+	// SSH options for ssh sources; path is read with cat unless command is set
+	SSHHost          string `yaml:"ssh_host,omitempty"`
+	SSHPort          int    `yaml:"ssh_port,omitempty"` // default 22
+	SSHUser          string `yaml:"ssh_user,omitempty"`
+	SSHKey           string `yaml:"ssh_key,omitempty"`             // private key file
+	Command          string `yaml:"command,omitempty"`             // remote command whose output is parsed
+	AcceptNewHostKey bool   `yaml:"accept_new_host_key,omitempty"` // trust unknown host keys on first use instead of requiring a known_hosts entry
+
+	// Options for perfcounter sources (Windows only), sampled with typeperf
+	Counters map[string]string `yaml:"counters,omitempty"` // counter path -> metric name, (*) labels by instance
+
+	// SQL options for sql sources, run through database/sql or the database's CLI client
+	Driver  string     `yaml:"driver,omitempty"` // sqlite, postgres or mysql
+	DSN     string     `yaml:"dsn,omitempty"`    // sqlite file path, or postgres:// / mysql:// URL
+	Queries []SQLQuery `yaml:"queries,omitempty"`
+}
+
+// SubSourceConfig is one of several sources feeding a single scraper,
+// with the metric maps that apply to its body
+type SubSourceConfig struct {
+	SourceConfig `yaml:",inline"`
+	Metrics      []MetricMap `yaml:"metrics"`
+}
+
+// SQLQuery maps one query of a sql source to metrics
+type SQLQuery struct {
+	Query string `yaml:"query"`
+	Name  string `yaml:"name,omitempty"` // first column of the first row; omit to emit every column of that row
 }
 
 type MetricMap struct {
-	Path      string `yaml:"path,omitempty"`      // for json
-	Match     string `yaml:"match,omitempty"`     // for prometheus/raw
+	Path      string `yaml:"path,omitempty"`  // for json
+	Match     string `yaml:"match,omitempty"` // for prometheus/raw, supports * globs
 	Name      string `yaml:"name"`
 	Calculate string `yaml:"calculate,omitempty"`
+	Precision *int   `yaml:"precision,omitempty"`  // round to N decimal places
+	Summarize string `yaml:"summarize,omitempty"`  // p50, p95, p99, mean, min, max, sum, count
+	LabelFrom string `yaml:"label_from,omitempty"` // label names for the path's "*" segments, comma-separated
+	Decode    string `yaml:"decode,omitempty"`     // base64, hex or number, applied before any other transformation
+
+	MinValue *float64 `yaml:"min_value,omitempty"` // drop the metric below this
+	MaxValue *float64 `yaml:"max_value,omitempty"` // drop the metric above this
+
+	Help string `yaml:"help,omitempty"` // description shown by ?meta=true
+	Unit string `yaml:"unit,omitempty"` // unit shown by ?meta=true, e.g. percent or bytes
 }
 
 type FilterConfig struct {
 	Include []string `yaml:"include"`
 	Exclude []string `yaml:"exclude"`
-}
\ No newline at end of file
+
+	MinValue *float64 `yaml:"min_value,omitempty"` // drop numeric values below this
+	MaxValue *float64 `yaml:"max_value,omitempty"` // drop numeric values above this
+}
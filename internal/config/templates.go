@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ScraperTemplate expands into one scraper per instance. Every string in
+// Template may reference instance variables as {{name}}.
+type ScraperTemplate struct {
+	Template  ScraperConfig       `yaml:"template"`
+	Instances []map[string]string `yaml:"instances"`
+}
+
+// ExpandTemplates appends a scraper for each template instance to Scrapers.
+// It should run right after the config is unmarshalled.
+func (c *Config) ExpandTemplates() error {
+	for i, tmpl := range c.ScraperTemplates {
+		for j, vars := range tmpl.Instances {
+			scraper := tmpl.Template
+			substitute(reflect.ValueOf(&scraper).Elem(), vars)
+
+			if strings.Contains(scraper.Name, "{{") {
+				return fmt.Errorf("scraper_templates[%d] instance %d: unresolved variable in name %q", i, j, scraper.Name)
+			}
+			c.Scrapers = append(c.Scrapers, scraper)
+		}
+	}
+	return nil
+}
+
+// substitute replaces {{var}} placeholders in every string reachable from v.
+// Slices, maps and pointers are copied so instances don't share state.
+func substitute(v reflect.Value, vars map[string]string) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandVars(v.String(), vars))
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			substitute(v.Field(i), vars)
+		}
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		copied := reflect.New(v.Elem().Type())
+		copied.Elem().Set(v.Elem())
+		substitute(copied.Elem(), vars)
+		v.Set(copied)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(copied, v)
+		for i := 0; i < copied.Len(); i++ {
+			substitute(copied.Index(i), vars)
+		}
+		v.Set(copied)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			val := reflect.New(iter.Value().Type()).Elem()
+			val.Set(iter.Value())
+			substitute(val, vars)
+			copied.SetMapIndex(iter.Key(), val)
+		}
+		v.Set(copied)
+	}
+}
+
+func expandVars(s string, vars map[string]string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	for name, value := range vars {
+		s = strings.ReplaceAll(s, "{{"+name+"}}", value)
+	}
+	return s
+}
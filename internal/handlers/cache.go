@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/auth"
+	"github.com/devatlogstyx/probestyx/internal/metrics"
+)
+
+// notModified reports whether the client's If-Modified-Since copy is still
+// current, so the request can be answered with 304 without collecting.
+// Scrapers are fetched live on every request, so this only applies when
+// the response comes entirely from the system cache.
+func notModified(r *http.Request) bool {
+	if len(cfg.Scrapers) > 0 || !cfg.System.Enabled {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	collectedAt, _, ok := metrics.CacheState()
+	return ok && !collectedAt.Truncate(time.Second).After(since)
+}
+
+// setCacheHeaders describes the freshness of the system metrics in the
+// response: Last-Modified is the collection time, Age how long ago that
+// was, and max-age the cache TTL. As in notModified, live scraper data
+// makes the response uncacheable.
+func setCacheHeaders(w http.ResponseWriter) {
+	if len(cfg.Scrapers) > 0 || !cfg.System.Enabled {
+		w.Header().Set("Cache-Control", "no-cache")
+		return
+	}
+	collectedAt, ttl, ok := metrics.CacheState()
+	if !ok {
+		w.Header().Set("Cache-Control", "no-cache")
+		return
+	}
+
+	// Authenticated responses must not be served to others by shared caches
+	visibility := "public"
+	if auth.Enabled() {
+		visibility = "private"
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(ttl.Seconds())))
+	w.Header().Set("Age", fmt.Sprint(int(time.Since(collectedAt).Seconds())))
+	w.Header().Set("Last-Modified", collectedAt.UTC().Format(http.TimeFormat))
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/auth"
+)
+
+// Upper bound on clients with a ?changed=true baseline; the least recently
+// seen is dropped to make room
+const maxChangedClients = 256
+
+// A client's last full result served with ?changed=true, diffed against
+// by its next one
+type changedBaseline struct {
+	result map[string]interface{}
+	seen   time.Time
+}
+
+var (
+	baselines   = make(map[string]*changedBaseline)
+	baselinesMu sync.Mutex
+)
+
+// changedOnly returns the parts of result that differ from client's
+// previous ?changed=true response and records result as its new baseline.
+// Keys in always (a leaf name or a dotted path such as "system.hostname")
+// are kept even when unchanged.
+func changedOnly(client string, result map[string]interface{}, always []string) map[string]interface{} {
+	keep := make(map[string]bool, len(always))
+	for _, key := range always {
+		keep[key] = true
+	}
+
+	baselinesMu.Lock()
+	defer baselinesMu.Unlock()
+
+	baseline, ok := baselines[client]
+	if !ok {
+		if len(baselines) >= maxChangedClients {
+			evictOldestBaseline()
+		}
+		baseline = &changedBaseline{}
+		baselines[client] = baseline
+	}
+
+	diff := diffMaps(result, baseline.result, "", keep)
+	baseline.result = result
+	baseline.seen = time.Now()
+	return diff
+}
+
+// evictOldestBaseline drops the least recently seen client's baseline.
+// Called with baselinesMu held.
+func evictOldestBaseline() {
+	var oldest string
+	var oldestSeen time.Time
+	for client, baseline := range baselines {
+		if oldest == "" || baseline.seen.Before(oldestSeen) {
+			oldest, oldestSeen = client, baseline.seen
+		}
+	}
+	delete(baselines, oldest)
+}
+
+// changedClient identifies whose baseline a ?changed=true request diffs
+// against: the ?client= id when given, otherwise the caller's address
+func changedClient(r *http.Request) string {
+	if id := r.URL.Query().Get("client"); id != "" {
+		return "id:" + id
+	}
+	return "ip:" + auth.ClientIP(r)
+}
+
+func diffMaps(current, previous map[string]interface{}, path string, keep map[string]bool) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for key, value := range current {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		if keep[key] || keep[keyPath] {
+			diff[key] = value
+			continue
+		}
+
+		prev, existed := previous[key]
+		nested, isMap := value.(map[string]interface{})
+		prevNested, prevIsMap := prev.(map[string]interface{})
+		if isMap && prevIsMap && !isTimestamped(nested) {
+			if inner := diffMaps(nested, prevNested, keyPath, keep); len(inner) > 0 {
+				diff[key] = inner
+			}
+			continue
+		}
+
+		if !existed || !reflect.DeepEqual(unwrapTimestamp(value), unwrapTimestamp(prev)) {
+			diff[key] = value
+		}
+	}
+	return diff
+}
+
+// isTimestamped reports whether m is a {"value", "timestamp"} wrapper added
+// by include_timestamps
+func isTimestamped(m map[string]interface{}) bool {
+	_, hasValue := m["value"]
+	_, hasTimestamp := m["timestamp"]
+	return len(m) == 2 && hasValue && hasTimestamp
+}
+
+// unwrapTimestamp compares wrapped values by value alone, since the
+// timestamp changes on every collection
+func unwrapTimestamp(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok && isTimestamped(m) {
+		return m["value"]
+	}
+	return v
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// queryFilter holds the ?include= and ?exclude= patterns of one request
+type queryFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// parseQueryFilter compiles the comma-separated include and exclude regexes
+// from query. It returns nil when neither parameter is present.
+func parseQueryFilter(query url.Values) (*queryFilter, error) {
+	if !query.Has("include") && !query.Has("exclude") {
+		return nil, nil
+	}
+
+	filter := &queryFilter{}
+	var err error
+	if filter.include, err = compilePatterns(query["include"]); err != nil {
+		return nil, err
+	}
+	if filter.exclude, err = compilePatterns(query["exclude"]); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func compilePatterns(values []string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, value := range values {
+		for _, pattern := range strings.Split(value, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+			patterns = append(patterns, re)
+		}
+	}
+	return patterns, nil
+}
+
+// apply keeps the metrics of result whose name or dotted path (e.g.
+// "system.cpu_count") matches an include pattern, if any are given, and no
+// exclude pattern, the same way scraper filters treat keys. Namespaces
+// left empty are dropped.
+func (f *queryFilter) apply(result map[string]interface{}, path string) map[string]interface{} {
+	filtered := make(map[string]interface{})
+	for key, value := range result {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok && !isTimestamped(nested) {
+			if inner := f.apply(nested, keyPath); len(inner) > 0 {
+				filtered[key] = inner
+			}
+			continue
+		}
+
+		if f.keep(key, keyPath) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+func (f *queryFilter) keep(key, keyPath string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, key, keyPath) {
+		return false
+	}
+	return !matchAny(f.exclude, key, keyPath)
+}
+
+func matchAny(patterns []*regexp.Regexp, key, keyPath string) bool {
+	for _, re := range patterns {
+		if re.MatchString(key) || re.MatchString(keyPath) {
+			return true
+		}
+	}
+	return false
+}
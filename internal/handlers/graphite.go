@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// Characters Graphite doesn't accept in a path segment
+var graphiteUnsafe = regexp.MustCompile(`[^A-Za-z0-9_\-]+`)
+
+// writeGraphite writes numeric metrics in Graphite plaintext format:
+// "path value timestamp\n", one line per metric, sorted by path.
+func writeGraphite(w io.Writer, result map[string]interface{}, prefix string, now time.Time) {
+	lines := make(map[string]float64)
+	collectGraphite(lines, result, strings.Trim(prefix, "."))
+
+	paths := make([]string, 0, len(lines))
+	for path := range lines {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	ts := now.Unix()
+	for _, path := range paths {
+		fmt.Fprintf(w, "%s %s %d\n", path, strconv.FormatFloat(lines[path], 'f', -1, 64), ts)
+	}
+}
+
+func collectGraphite(lines map[string]float64, value interface{}, path string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// Timestamp-wrapped values collapse back to the plain value
+		if inner, ok := v["value"]; ok && len(v) == 2 {
+			if _, ok := v["timestamp"]; ok {
+				collectGraphite(lines, inner, path)
+				return
+			}
+		}
+		for key, inner := range v {
+			collectGraphite(lines, inner, joinGraphitePath(path, key))
+		}
+	case []float64:
+		for i, inner := range v {
+			lines[joinGraphitePath(path, strconv.Itoa(i))] = inner
+		}
+	case []interface{}:
+		for i, inner := range v {
+			collectGraphite(lines, inner, joinGraphitePath(path, strconv.Itoa(i)))
+		}
+	default:
+		if utils.IsNumber(v) {
+			num, _ := utils.ToFloat64(v)
+			lines[path] = num
+		}
+	}
+}
+
+func joinGraphitePath(path, segment string) string {
+	segment = strings.Trim(graphiteUnsafe.ReplaceAllString(segment, "_"), "_")
+	if path == "" {
+		return segment
+	}
+	return path + "." + segment
+}
@@ -1,83 +1,554 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/devatlogstyx/probestyx/internal/auth"
 	"github.com/devatlogstyx/probestyx/internal/config"
 	"github.com/devatlogstyx/probestyx/internal/metrics"
+	"github.com/devatlogstyx/probestyx/internal/utils"
 )
 
 var cfg *config.Config
 
-func Init(c *config.Config) {
+// Version of the running binary, reported as probestyx_build_info
+var buildVersion string
+
+// Modification time and short hash of the loaded config file
+type configInfo struct {
+	mtime time.Time
+	hash  string
+}
+
+var loadedConfig atomic.Pointer[configInfo]
+
+// SetConfigInfo records the loaded config file's modification time and
+// hash, reported as probestyx_config_mtime and probestyx_config_hash
+func SetConfigInfo(mtime time.Time, hash string) {
+	loadedConfig.Store(&configInfo{mtime: mtime, hash: hash})
+}
+
+// Held for reading while a request (or remote-write push) uses the config,
+// and exclusively while Reload swaps it
+var reloadMu sync.RWMutex
+
+func Init(c *config.Config, version string) {
 	cfg = c
+	buildVersion = version
 	auth.Init(c)
 	metrics.Init(c)
+
+	initRequestLog(c.Server.LogRequests)
+
+	if s := c.Server.MergeStrategy; s != "" && s != "first" && s != "last" {
+		log.Printf("WARN: Unknown merge_strategy '%s', using last", s)
+	}
+
+	if c.RemoteWrite != nil && c.RemoteWrite.URL != "" {
+		metrics.StartRemoteWrite(*c.RemoteWrite, func() map[string]interface{} {
+			reloadMu.RLock()
+			defer reloadMu.RUnlock()
+			result, _, _ := collectAll(context.Background())
+			return result
+		})
+	}
+
+	if c.StatsD != nil && c.StatsD.Address != "" {
+		metrics.StartStatsD(*c.StatsD, func() map[string]interface{} {
+			reloadMu.RLock()
+			defer reloadMu.RUnlock()
+			result, _, _ := collectAll(context.Background())
+			return result
+		})
+	}
+}
+
+// Reload re-initializes every package with a new config. In-flight
+// requests finish with the old config first; the system cache is
+// invalidated since the requested metrics may have changed.
+func Reload(c *config.Config) {
+	// Stop background work before taking the lock, a push may be holding it
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := metrics.Shutdown(ctx); err != nil {
+		log.Printf("WARN: Background work did not stop before reload: %v", err)
+	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	Init(c, buildVersion)
 }
 
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("deep") == "true" {
+		deepHealth(w, r)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
+// deepHealth checks in parallel that every scraper's sources are reachable,
+// for gating traffic to a freshly deployed host. The report names internal
+// endpoints, so it is authenticated like /metrics.
+func deepHealth(w http.ResponseWriter, r *http.Request) {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+
+	if !authorize(w, r) {
+		return
+	}
+
+	reports := make(map[string]metrics.Reachability, len(cfg.Scrapers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, scraper := range cfg.Scrapers {
+		wg.Add(1)
+		go func(s config.ScraperConfig) {
+			defer wg.Done()
+			report := metrics.CheckReachability(s)
+			mu.Lock()
+			reports[s.Name] = report
+			mu.Unlock()
+		}(scraper)
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	for _, report := range reports {
+		if !report.Reachable {
+			status, code = "unreachable", http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   status,
+		"scrapers": reports,
+	})
+}
+
+// RefreshHandler clears the system metrics cache so the next collection is fresh
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+
+	if !authorize(w, r) {
+		return
+	}
+
+	metrics.InvalidateCache()
+	log.Printf("System metrics cache invalidated by %s", auth.ClientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorize validates credentials if an auth mechanism is configured,
+// writing a 401 and returning false when they are missing or wrong. The
+// response names the failed check, e.g. {"error":"timestamp_expired"},
+// so client misconfigurations can be told apart.
+func authorize(w http.ResponseWriter, r *http.Request) bool {
+	if !auth.Enabled() {
+		return true
+	}
+	err := auth.Authenticate(r)
+	if err == nil {
+		return true
+	}
+
+	log.Printf("Unauthorized request from %s: %v", auth.ClientIP(r), err)
+	w.Header().Set("WWW-Authenticate", auth.Challenge(err))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	return false
+}
+
 func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+
 	// Log who is requesting metrics
-	log.Printf("Metrics request from %s - User-Agent: %s", r.RemoteAddr, r.UserAgent())
+	logRequest(r)
 	
-	// Validate signature only if secret is configured
-	if cfg.Server.Secret != "" {
-		if !auth.ValidateSignature(r) {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+	if !authorize(w, r) {
+		return
+	}
+
+	// Bypass the system cache on demand
+	if r.URL.Query().Get("nocache") == "true" {
+		metrics.InvalidateCache()
+	} else if notModified(r) {
+		setCacheHeaders(w)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	filter, err := parseQueryFilter(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	debugTiming := r.URL.Query().Get("debug") == "timing"
+	requestStart := time.Now()
+
+	// Cap the whole collection so the response beats the scraper's own timeout
+	ctx := r.Context()
+	if cfg.Server.RequestTimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.Server.RequestTimeoutSeconds*float64(time.Second)))
+		defer cancel()
+	}
+
+	result, failures, scraperTimings := collectAll(ctx)
+
+	// In strict mode any scraper failure fails the whole request
+	strict := cfg.Server.StrictScrapers || r.URL.Query().Get("strict") == "true"
+	if strict && len(failures) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "scraper failures",
+			"failures": failures,
+		})
+		return
+	}
+	setCacheHeaders(w)
+
+	// Only report what changed since this client's previous ?changed=true request
+	if r.URL.Query().Get("changed") == "true" {
+		result = changedOnly(changedClient(r), result, cfg.Server.ChangedAlwaysInclude)
+	}
+
+	// Ad-hoc subset for dashboards, applied before any output format
+	if filter != nil {
+		result = filter.apply(result, "")
+	}
+
+	// Attach timing breakdown for diagnosing slow collection
+	if debugTiming {
+		result["_timing"] = map[string]interface{}{
+			"request_ms": float64(time.Since(requestStart).Microseconds()) / 1000,
+			"system":     metrics.LastCollectionTimings(),
+			"scrapers":   scraperTimings,
 		}
 	}
 
+	if r.URL.Query().Get("format") == "prometheus" {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.WritePrometheus(w, result)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "graphite" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeGraphite(w, result, cfg.Server.GraphitePrefix, time.Now())
+		return
+	}
+
+	// Units and help for UIs that render the JSON directly
+	if r.URL.Query().Get("meta") == "true" {
+		result = withMeta(result)
+	}
+
+	// Collapse every nesting level, arrays included, into dotted keys for
+	// consumers that can't walk nested objects
+	if r.URL.Query().Get("flat") == "true" {
+		result = utils.Flatten(result, "")
+	}
+
+	// encoding/json writes map keys in sorted order at every level, so the
+	// same metrics always encode identically regardless of the order
+	// scrapers finished in
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	if r.URL.Query().Get("pretty") == "true" {
+		encoder.SetIndent("", "  ")
+	}
+	if cfg.Server.OutputEnvelope {
+		encoder.Encode(envelope(result, requestStart))
+		return
+	}
+	encoder.Encode(result)
+}
+
+// envelope wraps result with the identity of the host that produced it,
+// for pipelines that expect metadata alongside every payload
+func envelope(result map[string]interface{}, collectedAt time.Time) map[string]interface{} {
+	host, err := os.Hostname()
+	if err != nil {
+		host = ""
+	}
+	return map[string]interface{}{
+		"host":         host,
+		"collected_at": collectedAt.UnixMilli(),
+		"version":      buildVersion,
+		"metrics":      result,
+	}
+}
+
+// collectAll gathers system and scraper metrics into one result keyed by
+// namespace, along with scraper failures and per-scraper durations in ms.
+// Collection stops when ctx is done: collectors are cancelled, whatever
+// finished is returned, sources still running are reported as failures and
+// the result gets a "_timeout": 1 marker.
+func collectAll(ctx context.Context) (map[string]interface{}, map[string]string, map[string]float64) {
 	result := make(map[string]interface{})
-	var mu sync.Mutex // Protect result map from concurrent writes
+	failures := make(map[string]string)
+	scraperTimings := make(map[string]float64)
+	var mu sync.Mutex // Protect result, failures and timings maps from concurrent writes
+
+	systemName := cfg.System.Name
+	if systemName == "" {
+		systemName = "system"
+	}
 
-	// Collect system metrics
+	// Sources still running, and whether the caller gave up on them. Late
+	// finishers discard their results once timedOut is set.
+	pending := make(map[string]bool)
 	if cfg.System.Enabled {
-		sysMetrics := metrics.CollectSystem()
-		systemName := cfg.System.Name
-		if systemName == "" {
-			systemName = "system"
-		}
-		result[systemName] = sysMetrics
+		pending[systemName] = true
+	}
+	for _, scraper := range cfg.Scrapers {
+		pending[scraper.Name] = true
 	}
+	timedOut := false
+
+	// Goroutines cut off by ctx can outlive the caller's hold on reloadMu,
+	// so they only read this snapshot, never cfg
+	includeTimestamps := cfg.Server.IncludeTimestamps
 
-	// Collect from scrapers in parallel
 	var wg sync.WaitGroup
-	for _, scraper := range cfg.Scrapers {
+
+	// Collect system metrics alongside the scrapers
+	if cfg.System.Enabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sysMetrics, collectedAt, err := metrics.CollectSystem(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			// A collector cut off by ctx stays pending for the timeout path
+			if timedOut || (err != nil && ctx.Err() != nil) {
+				return
+			}
+			delete(pending, systemName)
+			if err != nil {
+				log.Printf("Error collecting from %s: %v (skipping)", systemName, err)
+				failures[systemName] = err.Error()
+			} else if includeTimestamps {
+				result[systemName] = withTimestamps(sysMetrics, collectedAt)
+			} else {
+				result[systemName] = sysMetrics
+			}
+		}()
+	}
+
+	// Collect from scrapers in parallel. Results are merged afterwards in
+	// precedence order so key collisions resolve the same way every time.
+	scraperResults := make([]map[string]interface{}, len(cfg.Scrapers))
+	for i, scraper := range cfg.Scrapers {
 		wg.Add(1)
 		
 		// Capture scraper in closure
-		go func(s config.ScraperConfig) {
+		go func(i int, s config.ScraperConfig) {
 			defer wg.Done()
 			
-			scraperMetrics, err := metrics.CollectScraper(s)
+			scrapeStart := time.Now()
+			scraperMetrics, err := metrics.CollectScraper(ctx, s)
+			elapsed := time.Since(scrapeStart)
+			if err == nil && includeTimestamps {
+				scraperMetrics = withTimestamps(scraperMetrics, time.Now())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if timedOut || (err != nil && ctx.Err() != nil) {
+				return
+			}
+			delete(pending, s.Name)
+			scraperTimings[s.Name] = float64(elapsed.Microseconds()) / 1000
+
 			if err != nil {
 				log.Printf("Error collecting from %s: %v (skipping)", s.Name, err)
+				failures[s.Name] = err.Error()
 				return
 			}
+			scraperResults[i] = scraperMetrics
+		}(i, scraper)
+	}
 
-			mu.Lock()
-			defer mu.Unlock()
-			
-			// Check if this scraper name already exists
-			if _, exists := result[s.Name]; exists {
-				log.Printf("WARN: Scraper name '%s' already exists, overwriting previous value", s.Name)
+	// Wait for everything, or until the caller's budget runs out
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	// Collectors return early once ctx is done, so done may win the race
+	// even though some were cut off; whatever is still pending timed out
+	if ctx.Err() != nil {
+		mu.Lock()
+		timedOut = true
+		for name := range pending {
+			log.Printf("Error collecting from %s: request timeout reached (skipping)", name)
+			failures[name] = "request timeout reached"
+		}
+		if len(pending) > 0 {
+			result["_timeout"] = 1
+		}
+		mu.Unlock()
+	}
+
+	merged := make(map[string]bool)
+	for _, i := range mergeOrder(cfg.Scrapers, cfg.Server.MergeStrategy) {
+		if scraperResults[i] != nil {
+			mergeScraper(result, merged, cfg.Scrapers[i], scraperResults[i])
+		}
+	}
+
+	// probestyx's own series share one block, so they can't collide with
+	// scraper output key by key
+	self := make(map[string]interface{})
+
+	// Constant series identifying the running version for fleet inventory
+	if cfg.Server.BuildInfo {
+		self[utils.Labeled("build_info", "version", buildVersion)] = 1
+	}
+
+	// Lets deploys confirm a host picked up the new config
+	if info := loadedConfig.Load(); info != nil {
+		self["config_mtime"] = info.mtime.Unix()
+		self[utils.Labeled("config_hash", "hash", info.hash)] = 1
+	}
+
+	// Health after failure/recovery thresholds, steadier than raw failures
+	for _, scraper := range cfg.Scrapers {
+		if up, ok := metrics.ScraperUp(scraper.Name); ok {
+			value := 0
+			if up {
+				value = 1
 			}
-			
-			result[s.Name] = scraperMetrics
-		}(scraper)
+			self[utils.Labeled("scraper_up", "scraper", scraper.Name)] = value
+		}
 	}
 
-	wg.Wait() // Wait for all scrapers to complete
+	// Growth here means per-scraper state isn't being evicted
+	self["scraper_state_entries"] = metrics.StateSize()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
-}
\ No newline at end of file
+	if cfg.Server.SelfMetrics {
+		addRuntimeMetrics(self)
+	}
+
+	if _, exists := result[selfMetricsKey]; exists {
+		log.Printf("WARN: key '%s' is reserved for probestyx's own metrics, overwriting previous value", selfMetricsKey)
+	}
+	result[selfMetricsKey] = self
+
+	return result, failures, scraperTimings
+}
+
+// mergeOrder returns scraper indexes in the order their results are
+// merged, so the last one wins a key collision. Higher priority always
+// wins; among equal priorities the later scraper in the config wins, or
+// the earlier one with the "first" strategy.
+func mergeOrder(scrapers []config.ScraperConfig, strategy string) []int {
+	order := make([]int, len(scrapers))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := scrapers[order[a]].Priority, scrapers[order[b]].Priority
+		if pa != pb {
+			return pa < pb
+		}
+		if strategy == "first" {
+			return order[a] > order[b]
+		}
+		return order[a] < order[b]
+	})
+	return order
+}
+
+// mergeScraper places a scraper's metrics into result according to its
+// namespace: "nested" (default) under the scraper name, "flat" at the top
+// level, or any other string as a key shared with other scrapers. Later
+// scrapers win collisions. merged records namespace maps created here so
+// maps owned by other code (e.g. the cached system metrics) are copied
+// before being written to.
+func mergeScraper(result map[string]interface{}, merged map[string]bool, s config.ScraperConfig, scraperMetrics map[string]interface{}) {
+	switch s.Namespace {
+	case "", "nested":
+		if _, exists := result[s.Name]; exists {
+			log.Printf("WARN: Scraper name '%s' already exists, overwriting previous value", s.Name)
+		}
+		result[s.Name] = scraperMetrics
+	case "flat":
+		for key, value := range scraperMetrics {
+			if _, exists := result[key]; exists {
+				log.Printf("WARN: Scraper %s: top-level key '%s' already exists, overwriting previous value", s.Name, key)
+			}
+			result[key] = value
+		}
+	default:
+		target, ok := result[s.Namespace].(map[string]interface{})
+		if !merged[s.Namespace] {
+			if _, exists := result[s.Namespace]; exists && !ok {
+				log.Printf("WARN: Scraper %s: namespace '%s' holds a non-object value, overwriting it", s.Name, s.Namespace)
+			}
+			copied := make(map[string]interface{}, len(target)+len(scraperMetrics))
+			for key, value := range target {
+				copied[key] = value
+			}
+			target = copied
+			result[s.Namespace] = target
+			merged[s.Namespace] = true
+		}
+		for key, value := range scraperMetrics {
+			if _, exists := target[key]; exists {
+				log.Printf("WARN: Scraper %s: key '%s' already exists in namespace '%s', overwriting previous value", s.Name, key, s.Namespace)
+			}
+			target[key] = value
+		}
+	}
+}
+
+// withTimestamps returns a copy of m with every numeric value wrapped as
+// {"value": X, "timestamp": <unix_ms>}. Nested maps are walked recursively.
+func withTimestamps(m map[string]interface{}, at time.Time) map[string]interface{} {
+	ts := at.UnixMilli()
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			out[key] = withTimestamps(v, at)
+		default:
+			if utils.IsNumber(v) {
+				out[key] = map[string]interface{}{"value": v, "timestamp": ts}
+			} else {
+				out[key] = v
+			}
+		}
+	}
+	return out
+}
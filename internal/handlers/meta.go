@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/devatlogstyx/probestyx/internal/metrics"
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// metaEntry is what ?meta=true reports for a metric beyond its value
+type metaEntry struct {
+	metrics.MetricMeta
+	Type string
+}
+
+// scraperMeta collects the help and unit of every scraper metric map that
+// sets one, keyed by output name, plus the help and type captured from
+// keep_metadata sources. Glob maps, whose outputs are named <name>_<key>,
+// are returned separately as prefixes.
+func scraperMeta() (map[string]metaEntry, map[string]metaEntry) {
+	exact := make(map[string]metaEntry)
+	prefixes := make(map[string]metaEntry)
+	for _, scraper := range cfg.Scrapers {
+		for name, scraped := range metrics.ScrapedMetadata(scraper.Name) {
+			exact[name] = metaEntry{MetricMeta: metrics.MetricMeta{Help: scraped.Help}, Type: scraped.Type}
+		}
+
+		maps := scraper.Metrics
+		for _, sub := range scraper.Sources {
+			maps = append(maps, sub.Metrics...)
+		}
+		for _, metricMap := range maps {
+			if metricMap.Help == "" && metricMap.Unit == "" {
+				continue
+			}
+			meta := metrics.MetricMeta{Unit: metricMap.Unit, Help: metricMap.Help}
+			if metricMap.Match != "" && strings.ContainsAny(metricMap.Match, "*?[") {
+				prefixes[metricMap.Name+"_"] = metaEntry{MetricMeta: meta}
+			} else {
+				// Configured help wins over the scraped one; the type is kept
+				entry := exact[metricMap.Name]
+				if meta.Help == "" {
+					meta.Help = entry.Help
+				}
+				exact[metricMap.Name] = metaEntry{MetricMeta: meta, Type: entry.Type}
+			}
+		}
+	}
+	return exact, prefixes
+}
+
+// withMeta wraps every metric in result as {"value": X, "unit": ..., "help":
+// ...} for ?meta=true. Units and help come from the built-in table for
+// system metrics and from help/unit on scraper metric maps, and "type"
+// from keep_metadata sources; each is omitted when unknown. Keys starting with "_" (debug blocks) are left as
+// they are.
+func withMeta(result map[string]interface{}) map[string]interface{} {
+	exact, prefixes := scraperMeta()
+	lookup := func(key string, system bool) (metaEntry, bool) {
+		if system {
+			if meta, ok := metrics.SystemMetricMeta(key); ok {
+				return metaEntry{MetricMeta: meta}, true
+			}
+		}
+		name, _ := utils.ParseLabeled(key)
+		if meta, ok := exact[name]; ok {
+			return meta, true
+		}
+		longest := ""
+		for prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) && len(prefix) > len(longest) {
+				longest = prefix
+			}
+		}
+		if longest != "" {
+			return prefixes[longest], true
+		}
+		return metaEntry{}, false
+	}
+
+	systemName := cfg.System.Name
+	if systemName == "" {
+		systemName = "system"
+	}
+
+	out := make(map[string]interface{}, len(result))
+	for key, value := range result {
+		if strings.HasPrefix(key, "_") {
+			out[key] = value
+			continue
+		}
+		out[key] = wrapMeta(key, value, cfg.System.Enabled && key == systemName, lookup)
+	}
+	return out
+}
+
+func wrapMeta(key string, value interface{}, system bool, lookup func(string, bool) (metaEntry, bool)) interface{} {
+	wrapped := map[string]interface{}{"value": value}
+	if nested, ok := value.(map[string]interface{}); ok {
+		// include_timestamps wrappers gain the meta fields alongside
+		if isTimestamped(nested) {
+			wrapped = map[string]interface{}{"value": nested["value"], "timestamp": nested["timestamp"]}
+		} else {
+			out := make(map[string]interface{}, len(nested))
+			for innerKey, inner := range nested {
+				out[innerKey] = wrapMeta(innerKey, inner, system, lookup)
+			}
+			return out
+		}
+	}
+
+	if meta, ok := lookup(key, system); ok {
+		if meta.Unit != "" {
+			wrapped["unit"] = meta.Unit
+		}
+		if meta.Help != "" {
+			wrapped["help"] = meta.Help
+		}
+		if meta.Type != "" {
+			wrapped["type"] = meta.Type
+		}
+	}
+	return wrapped
+}
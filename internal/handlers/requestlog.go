@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/devatlogstyx/probestyx/internal/auth"
+)
+
+// Log one in logEvery /metrics requests; 0 disables the log line
+var (
+	logEvery     atomic.Int64
+	requestCount atomic.Int64
+)
+
+// initRequestLog parses log_requests: all (default), none, or sample:N
+func initRequestLog(mode string) {
+	every := int64(1)
+	switch {
+	case mode == "" || mode == "all":
+	case mode == "none":
+		every = 0
+	case strings.HasPrefix(mode, "sample:"):
+		n, err := strconv.ParseInt(strings.TrimPrefix(mode, "sample:"), 10, 64)
+		if err != nil || n < 1 {
+			log.Printf("WARN: Invalid log_requests '%s', logging every request", mode)
+			break
+		}
+		every = n
+	default:
+		log.Printf("WARN: Unknown log_requests '%s', logging every request", mode)
+	}
+	logEvery.Store(every)
+}
+
+// logRequest logs who is requesting metrics, subject to log_requests
+func logRequest(r *http.Request) {
+	every := logEvery.Load()
+	if every == 0 {
+		return
+	}
+	if (requestCount.Add(1)-1)%every != 0 {
+		return
+	}
+	log.Printf("Metrics request from %s - User-Agent: %s", auth.ClientIP(r), r.UserAgent())
+}
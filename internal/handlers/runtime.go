@@ -0,0 +1,29 @@
+package handlers
+
+import "runtime"
+
+// Key of the block holding probestyx's own metrics. Nested keys are joined
+// with "_" in the Prometheus and Graphite outputs, so these still appear
+// there as probestyx_build_info, probestyx_goroutines and so on.
+const selfMetricsKey = "probestyx"
+
+// addRuntimeMetrics adds probestyx's own Go runtime footprint to its
+// block, so a goroutine or heap leak from a misbehaving scraper becomes
+// visible
+func addRuntimeMetrics(self map[string]interface{}) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	var lastPause float64
+	if ms.NumGC > 0 {
+		lastPause = float64(ms.PauseNs[(ms.NumGC+255)%256]) / 1e6
+	}
+
+	self["goroutines"] = runtime.NumGoroutine()
+	self["heap_alloc_bytes"] = ms.HeapAlloc
+	self["heap_objects"] = ms.HeapObjects
+	self["sys_bytes"] = ms.Sys
+	self["gc_count"] = ms.NumGC
+	self["gc_pause_ms"] = lastPause
+	self["gc_pause_total_ms"] = float64(ms.PauseTotalNs) / 1e6
+}
@@ -0,0 +1,35 @@
+package metrics
+
+// Battery state read by the platform-specific readBattery
+type batteryInfo struct {
+	percent  float64
+	charging bool
+	plugged  bool // on external power
+}
+
+// collectBattery sends the requested power metrics. Hosts without a
+// battery send nothing.
+func collectBattery(send func(string, interface{})) {
+	info, ok, err := readBattery()
+	if err != nil || !ok {
+		return
+	}
+
+	if requestedMetrics["battery_percent"] {
+		send("battery_percent", round(info.percent))
+	}
+	if requestedMetrics["battery_charging"] {
+		charging := 0
+		if info.charging {
+			charging = 1
+		}
+		send("battery_charging", charging)
+	}
+	if requestedMetrics["power_plugged"] {
+		plugged := 0
+		if info.plugged {
+			plugged = 1
+		}
+		send("power_plugged", plugged)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+// readBattery reports charge and power source from /sys/class/power_supply.
+// Multiple batteries are averaged. ok is false when the host has no battery.
+func readBattery() (info batteryInfo, ok bool, err error) {
+	entries, err := os.ReadDir(powerSupplyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return info, false, nil
+		}
+		return info, false, err
+	}
+
+	var batteries int
+	var capacityTotal float64
+	var mainsFound bool
+	for _, entry := range entries {
+		dir := filepath.Join(powerSupplyDir, entry.Name())
+		switch readSysfs(dir, "type") {
+		case "Battery":
+			// Peripheral batteries (mice, keyboards) report scope Device
+			if readSysfs(dir, "scope") == "Device" {
+				continue
+			}
+			capacity, err := strconv.ParseFloat(readSysfs(dir, "capacity"), 64)
+			if err != nil {
+				continue
+			}
+			batteries++
+			capacityTotal += capacity
+			switch readSysfs(dir, "status") {
+			case "Charging":
+				info.charging = true
+				info.plugged = true
+			case "Full", "Not charging":
+				info.plugged = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if readSysfs(dir, "online") == "1" {
+				mainsFound = true
+			}
+		}
+	}
+
+	if batteries == 0 {
+		return info, false, nil
+	}
+	info.percent = capacityTotal / float64(batteries)
+	info.plugged = info.plugged || mainsFound
+	return info, true, nil
+}
+
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
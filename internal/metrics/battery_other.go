@@ -0,0 +1,10 @@
+//go:build !linux
+
+package metrics
+
+import "errors"
+
+// readBattery is only implemented on Linux
+func readBattery() (info batteryInfo, ok bool, err error) {
+	return info, false, errors.New("battery metrics not supported on this platform")
+}
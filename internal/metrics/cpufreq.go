@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// readCPUFrequencies returns the current clock of each logical CPU in MHz.
+// Linux cpufreq reports the live scaling frequency; elsewhere, and on VMs
+// without cpufreq, cpu.Info's nominal or last-read clock is used. An empty
+// result means the platform exposes no frequency.
+func readCPUFrequencies(ctx context.Context) ([]float64, error) {
+	if freqs := readCPUFreqSysfs(); len(freqs) > 0 {
+		return freqs, nil
+	}
+
+	infos, err := cpu.InfoWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	freqs := make([]float64, 0, len(infos))
+	for _, info := range infos {
+		if info.Mhz > 0 {
+			freqs = append(freqs, info.Mhz)
+		}
+	}
+	return freqs, nil
+}
+
+// readCPUFreqSysfs reads scaling_cur_freq (kHz) for every CPU, ordered by
+// CPU number
+func readCPUFreqSysfs() []float64 {
+	paths, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
+	sort.Slice(paths, func(i, j int) bool {
+		return cpuIndex(paths[i]) < cpuIndex(paths[j])
+	})
+
+	freqs := make([]float64, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		khz, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err != nil || khz <= 0 {
+			continue
+		}
+		freqs = append(freqs, khz/1000)
+	}
+	return freqs
+}
+
+// cpuIndex extracts N from .../cpuN/cpufreq/...
+func cpuIndex(path string) int {
+	dir := filepath.Base(filepath.Dir(filepath.Dir(path)))
+	n, _ := strconv.Atoi(strings.TrimPrefix(dir, "cpu"))
+	return n
+}
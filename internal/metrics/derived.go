@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"log"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// applyDerived evaluates the configured derived metrics against the
+// collected values and adds the results to metrics. Derived metrics may
+// reference ones defined earlier in the list.
+func applyDerived(metrics map[string]interface{}) {
+	if len(cfg.System.Derived) == 0 {
+		return
+	}
+
+	vars := make(map[string]float64, len(metrics)+len(cfg.System.Derived))
+	for key, value := range metrics {
+		if num, ok := utils.ToFloat64(value); ok {
+			vars[key] = num
+		}
+	}
+
+	for _, d := range cfg.System.Derived {
+		value, err := utils.Evaluate(d.Expression, vars)
+		if err != nil {
+			log.Printf("Error evaluating derived metric %s: %v (skipping)", d.Name, err)
+			continue
+		}
+		value = round(value)
+		metrics[d.Name] = value
+		vars[d.Name] = value
+	}
+}
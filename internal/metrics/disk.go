@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// Pseudo filesystems skipped during mount discovery
+var defaultExcludedFSTypes = []string{
+	"autofs", "binfmt_misc", "bpf", "cgroup", "cgroup2", "configfs", "debugfs",
+	"devpts", "devtmpfs", "fusectl", "hugetlbfs", "mqueue", "nsfs", "overlay",
+	"proc", "pstore", "ramfs", "rpc_pipefs", "securityfs", "squashfs", "sysfs",
+	"tmpfs", "tracefs",
+}
+
+// Filesystem types excluded from discovery, built at Init
+var excludedFSTypes map[string]bool
+
+func initDiskDiscovery() {
+	excludedFSTypes = make(map[string]bool, len(defaultExcludedFSTypes)+len(cfg.System.ExcludeFSTypes))
+	for _, fstype := range defaultExcludedFSTypes {
+		excludedFSTypes[fstype] = true
+	}
+	for _, fstype := range cfg.System.ExcludeFSTypes {
+		excludedFSTypes[fstype] = true
+	}
+}
+
+// Mounted partitions and filesystem usage; variables so tests can
+// substitute fakes
+var (
+	diskPartitions = func(ctx context.Context) ([]disk.PartitionStat, error) {
+		return disk.PartitionsWithContext(ctx, false)
+	}
+	diskUsage = disk.UsageWithContext
+)
+
+// discoverMounts returns the partitions of real filesystems, one per
+// mount point
+func discoverMounts(ctx context.Context) ([]disk.PartitionStat, error) {
+	partitions, err := diskPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(partitions))
+	mounts := make([]disk.PartitionStat, 0, len(partitions))
+	for _, p := range partitions {
+		if excludedFSTypes[p.Fstype] || seen[p.Mountpoint] {
+			continue
+		}
+		seen[p.Mountpoint] = true
+		mounts = append(mounts, p)
+	}
+	return mounts, nil
+}
+
+// uniqueDevices keeps the first mount of each device, so a filesystem
+// mounted more than once (bind mounts, btrfs subvolumes) is counted once.
+// Partitions without a device name, or named "none", are all kept.
+func uniqueDevices(mounts []disk.PartitionStat) []disk.PartitionStat {
+	seen := make(map[string]bool, len(mounts))
+	unique := make([]disk.PartitionStat, 0, len(mounts))
+	for _, p := range mounts {
+		if p.Device != "" && p.Device != "none" {
+			if seen[p.Device] {
+				continue
+			}
+			seen[p.Device] = true
+		}
+		unique = append(unique, p)
+	}
+	return unique
+}
+
+// collectMountUsage emits the requested disk usage metrics for every
+// discovered mount, labeled by mount point.
+func collectMountUsage(ctx context.Context, send func(string, interface{})) {
+	mounts, err := discoverMounts(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, p := range mounts {
+		mount := p.Mountpoint
+		usage, err := diskUsage(ctx, mount)
+		if err != nil {
+			continue
+		}
+
+		if requestedMetrics["disk_usage_percent"] {
+			send(utils.Labeled("disk_usage_percent", "mount", mount), round(usage.UsedPercent))
+		}
+		if requestedMetrics["available_disk_gb"] {
+			sendSize(send, "available_disk_gb", usage.Free, "mount", mount)
+		}
+		if requestedMetrics["total_disk_gb"] {
+			sendSize(send, "total_disk_gb", usage.Total, "mount", mount)
+		}
+		if requestedMetrics["inode_usage_percent"] {
+			send(utils.Labeled("inode_usage_percent", "mount", mount), round(usage.InodesUsedPercent))
+		}
+		if requestedMetrics["inodes_total"] {
+			send(utils.Labeled("inodes_total", "mount", mount), usage.InodesTotal)
+		}
+		if requestedMetrics["inodes_used"] {
+			send(utils.Labeled("inodes_used", "mount", mount), usage.InodesUsed)
+		}
+		if requestedMetrics["inodes_free"] {
+			send(utils.Labeled("inodes_free", "mount", mount), usage.InodesFree)
+		}
+	}
+}
+
+// collectWeightedDiskUsage emits used space as a percentage of total space
+// summed over every discovered filesystem, so large filesystems weigh more
+// than small ones. Like disk_usage_percent (and df), total excludes space
+// reserved for root.
+func collectWeightedDiskUsage(ctx context.Context, send func(string, interface{})) {
+	mounts, err := discoverMounts(ctx)
+	if err != nil {
+		return
+	}
+
+	var used, total uint64
+	for _, p := range uniqueDevices(mounts) {
+		usage, err := diskUsage(ctx, p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		used += usage.Used
+		total += usage.Used + usage.Free
+	}
+
+	if total > 0 {
+		send("disk_usage_weighted_percent", round(float64(used)/float64(total)*100))
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func TestWeightedDiskUsageCountsEachDeviceOnce(t *testing.T) {
+	const gb = 1 << 30
+	partitions := []disk.PartitionStat{
+		{Device: "/dev/sda1", Mountpoint: "/", Fstype: "btrfs"},
+		{Device: "/dev/sda1", Mountpoint: "/home", Fstype: "btrfs"},     // subvolume
+		{Device: "/dev/sda1", Mountpoint: "/srv/data", Fstype: "btrfs"}, // bind mount
+		{Device: "/dev/sdb1", Mountpoint: "/backup", Fstype: "ext4"},
+		{Device: "tmpfs", Mountpoint: "/tmp", Fstype: "tmpfs"},
+	}
+	usage := map[string]*disk.UsageStat{
+		"/":         {Used: 90 * gb, Free: 10 * gb},
+		"/home":     {Used: 90 * gb, Free: 10 * gb},
+		"/srv/data": {Used: 90 * gb, Free: 10 * gb},
+		"/backup":   {Used: 10 * gb, Free: 90 * gb},
+		"/tmp":      {Used: 0, Free: 1 * gb},
+	}
+
+	originalPartitions, originalUsage := diskPartitions, diskUsage
+	diskPartitions = func(context.Context) ([]disk.PartitionStat, error) { return partitions, nil }
+	diskUsage = func(_ context.Context, path string) (*disk.UsageStat, error) { return usage[path], nil }
+	defer func() { diskPartitions, diskUsage = originalPartitions, originalUsage }()
+
+	Init(&config.Config{System: config.SystemConfig{Enabled: true, Metrics: []string{"disk_usage_weighted_percent", "disk_usage_percent"}}})
+	defer Shutdown(context.Background())
+
+	got := make(map[string]interface{})
+	send := func(key string, value interface{}) { got[key] = value }
+
+	// 100 GB used of 200 GB across the two devices
+	collectWeightedDiskUsage(context.Background(), send)
+	if want := 50.0; got["disk_usage_weighted_percent"] != want {
+		t.Errorf("disk_usage_weighted_percent = %v, want %v", got["disk_usage_weighted_percent"], want)
+	}
+
+	// Per-mount usage still reports every mount point
+	collectMountUsage(context.Background(), send)
+	for _, mount := range []string{"/", "/home", "/srv/data", "/backup"} {
+		if key := `disk_usage_percent{mount="` + mount + `"}`; got[key] == nil {
+			t.Errorf("missing %s", key)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+//go:build linux
+
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readFileDescriptors returns the system-wide allocated and maximum file
+// descriptor counts from /proc/sys/fs/file-nr.
+func readFileDescriptors() (open uint64, max uint64, err error) {
+	data, err := os.ReadFile("/proc/sys/fs/file-nr")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Format: <allocated> <allocated but unused> <max>
+	fields := strings.Fields(string(data))
+	if len(fields) != 3 {
+		return 0, 0, fmt.Errorf("unexpected file-nr format: %q", data)
+	}
+
+	allocated, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	unused, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err = strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return allocated - unused, max, nil
+}
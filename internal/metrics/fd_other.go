@@ -0,0 +1,10 @@
+//go:build !linux
+
+package metrics
+
+import "errors"
+
+// readFileDescriptors is only implemented on Linux
+func readFileDescriptors() (open uint64, max uint64, err error) {
+	return 0, 0, errors.New("file descriptor metrics not supported on this platform")
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package metrics
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// How long to wait for data from a FIFO writer
+const fifoReadTimeout = time.Second
+
+// readFIFO reads whatever is available from a named pipe without blocking
+// on a missing or stalled writer.
+func readFIFO(path string, limit int64) (string, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := f.SetReadDeadline(time.Now().Add(fifoReadTimeout)); err != nil {
+		return "", err
+	}
+
+	// EOF (no or closed writer) or the deadline both end the read; keep
+	// whatever arrived before then
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return "", err
+	}
+
+	return checkLimit(data, limit, path)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package metrics
+
+import "errors"
+
+// readFIFO is unsupported; Windows named pipes work differently
+func readFIFO(path string, limit int64) (string, error) {
+	return "", errors.New("fifo sources are not supported on Windows")
+}
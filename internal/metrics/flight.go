@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// scrapeCall is one in-flight scrape that concurrent callers wait on
+type scrapeCall struct {
+	done    chan struct{}
+	result  map[string]interface{}
+	err     error
+	waiters int                // callers still waiting on the result
+	cancel  context.CancelFunc // stops the scrape once every waiter has gone
+}
+
+// In-flight scrapes by scraper name. Like collectionMutex for system
+// metrics, this keeps simultaneous /metrics requests from each hitting
+// the same upstream. golang.org/x/sync/singleflight covers the sharing
+// but not stopping a scrape once every caller has given up, hence the
+// waiter count here.
+var (
+	inFlight   = make(map[string]*scrapeCall)
+	inFlightMu sync.Mutex
+)
+
+// shareScrape runs fn for the named scraper unless a scrape of it is
+// already in progress, in which case it waits for and returns that
+// scrape's result. Every caller gets its own copy, so callers may modify
+// the map without racing each other.
+//
+// A caller whose ctx ends stops waiting and gets ctx's error. The scrape
+// itself runs on its own context, cancelled only when the last waiter
+// gives up, so one request timing out doesn't fail the others.
+func shareScrape(ctx context.Context, name string, fn func(context.Context) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	inFlightMu.Lock()
+	call, ok := inFlight[name]
+	if !ok {
+		scrapeCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &scrapeCall{done: make(chan struct{}), cancel: cancel}
+		inFlight[name] = call
+		go func() {
+			result, err := fn(scrapeCtx)
+			inFlightMu.Lock()
+			if inFlight[name] == call {
+				delete(inFlight, name)
+			}
+			call.result, call.err = result, err
+			inFlightMu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	inFlightMu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copyMetrics(call.result), nil
+	case <-ctx.Done():
+		inFlightMu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody wants the result any more; later callers start afresh
+			call.cancel()
+			if inFlight[name] == call {
+				delete(inFlight, name)
+			}
+		}
+		inFlightMu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// copyMetrics deep-copies a metrics map, including nested maps and slices
+func copyMetrics(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		out[key] = copyValue(value)
+	}
+	return out
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyMetrics(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, inner := range v {
+			out[i] = copyValue(inner)
+		}
+		return out
+	case []float64:
+		return append([]float64(nil), v...)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShareScrapeRunsOncePerFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (map[string]interface{}, error) {
+		calls.Add(1)
+		<-release
+		return map[string]interface{}{"nested": map[string]interface{}{"up": 1}}, nil
+	}
+
+	const callers = 8
+	results := make([]map[string]interface{}, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := shareScrape(context.Background(), "shared", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = result
+		}(i)
+	}
+	waitForWaiters(t, "shared", callers)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn ran %d times for concurrent callers, want 1", got)
+	}
+
+	// Each caller owns its copy, nested maps included
+	results[0]["nested"].(map[string]interface{})["up"] = 0
+	for i, result := range results[1:] {
+		if result["nested"].(map[string]interface{})["up"] != 1 {
+			t.Errorf("caller %d saw another caller's write", i+1)
+		}
+	}
+
+	// A finished flight isn't reused
+	release = make(chan struct{})
+	close(release)
+	if _, err := shareScrape(context.Background(), "shared", fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("fn ran %d times after the flight finished, want 2", got)
+	}
+}
+
+func TestShareScrapeCancellation(t *testing.T) {
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+	fn := func(ctx context.Context) (map[string]interface{}, error) {
+		select {
+		case <-release:
+			return map[string]interface{}{"up": 1}, nil
+		case <-ctx.Done():
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+	}
+
+	// One caller giving up leaves the scrape running for the other
+	early, cancelEarly := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := shareScrape(early, "cancel", fn)
+		errs <- err
+	}()
+	go func() {
+		_, err := shareScrape(context.Background(), "cancel", fn)
+		errs <- err
+	}()
+	waitForWaiters(t, "cancel", 2)
+	cancelEarly()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-errs; err != nil {
+		t.Errorf("remaining caller got %v", err)
+	}
+
+	// The last caller giving up stops the scrape
+	release = make(chan struct{})
+	last, cancelLast := context.WithCancel(context.Background())
+	go func() {
+		_, err := shareScrape(last, "cancel", fn)
+		errs <- err
+	}()
+	waitForWaiters(t, "cancel", 1)
+	cancelLast()
+	<-errs
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("scrape kept running after its last caller gave up")
+	}
+}
+
+// waitForWaiters blocks until n callers are waiting on name's flight
+func waitForWaiters(t *testing.T, name string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		inFlightMu.Lock()
+		call := inFlight[name]
+		waiting := call != nil && call.waiters == n
+		inFlightMu.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d callers never joined the %s flight", n, name)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import "strings"
+
+// Category prefixes used when system metrics are grouped. Order matters:
+// the first matching prefix wins.
+var metricCategories = []struct {
+	category string
+	prefixes []string
+}{
+	{"cpu", []string{"cpu_"}},
+	{"memory", []string{"ram_", "available_ram", "total_ram", "swap_"}},
+	{"disk", []string{"disk_", "available_disk", "total_disk", "inode_", "inodes_"}},
+	{"network", []string{"network_", "active_connections", "listening_ports"}},
+	{"services", []string{"service_"}},
+	{"power", []string{"battery_", "power_"}},
+	{"host", []string{"system_", "boot_", "os_", "hostname", "kernel_", "process_", "open_file_", "max_file_", "clock_"}},
+}
+
+// groupMetrics nests a flat system metric map into category sub-maps.
+// Keys that don't belong to a known category stay at the top level.
+func groupMetrics(flat map[string]interface{}) map[string]interface{} {
+	grouped := make(map[string]interface{}, len(metricCategories))
+
+	for key, value := range flat {
+		category := metricCategory(key)
+		if category == "" {
+			grouped[key] = value
+			continue
+		}
+
+		sub, ok := grouped[category].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			grouped[category] = sub
+		}
+		sub[key] = value
+	}
+
+	return grouped
+}
+
+func metricCategory(key string) string {
+	for _, c := range metricCategories {
+		for _, prefix := range c.prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return c.category
+			}
+		}
+	}
+	return ""
+}
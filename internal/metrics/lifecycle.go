@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// Background work started by the metrics package is tied to this context so
+// Shutdown can stop it and wait for it to exit.
+var (
+	bgCtx    context.Context
+	bgCancel context.CancelFunc
+	bgWG     sync.WaitGroup
+	bgMu     sync.Mutex
+)
+
+func initBackground() {
+	bgMu.Lock()
+	defer bgMu.Unlock()
+
+	if bgCancel == nil {
+		bgCtx, bgCancel = context.WithCancel(context.Background())
+	}
+}
+
+// goBackground runs fn in a goroutine tracked by Shutdown. fn must return
+// once ctx is cancelled.
+func goBackground(fn func(ctx context.Context)) {
+	bgMu.Lock()
+	ctx := bgCtx
+	bgMu.Unlock()
+
+	if ctx == nil {
+		return
+	}
+
+	bgWG.Add(1)
+	go func() {
+		defer bgWG.Done()
+		fn(ctx)
+	}()
+}
+
+// Shutdown stops background collectors and waits for them to exit, or
+// until ctx is done.
+func Shutdown(ctx context.Context) error {
+	bgMu.Lock()
+	if bgCancel != nil {
+		bgCancel()
+		bgCancel = nil
+		bgCtx = nil
+	}
+	bgMu.Unlock()
+	removeSSHControlDir()
+
+	done := make(chan struct{})
+	go func() {
+		bgWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
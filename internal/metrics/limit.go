@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"log"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// Scrapers whose last output was cut by top_k or limit, so the cut is
+// logged when it starts and ends rather than on every scrape
+var (
+	limitedScrapers   = make(map[string]bool)
+	limitedScrapersMu sync.Mutex
+)
+
+// setLimited records whether the scraper's output is being cut and
+// reports whether that changed
+func setLimited(name string, limited bool) bool {
+	limitedScrapersMu.Lock()
+	defer limitedScrapersMu.Unlock()
+	if limitedScrapers[name] == limited {
+		return false
+	}
+	if limited {
+		limitedScrapers[name] = true
+	} else {
+		delete(limitedScrapers, name)
+	}
+	return true
+}
+
+// limitMetrics caps a scraper's output. top_k keeps the N highest numeric
+// values, then limit keeps at most N metrics. Ties and non-numeric values
+// are ordered by key so the same input always keeps the same metrics.
+func limitMetrics(result map[string]interface{}, scraper config.ScraperConfig) map[string]interface{} {
+	keep := len(result)
+	if scraper.TopK > 0 && scraper.TopK < keep {
+		keep = scraper.TopK
+	}
+	if scraper.Limit > 0 && scraper.Limit < keep {
+		keep = scraper.Limit
+	}
+	if keep == len(result) {
+		if setLimited(scraper.Name, false) {
+			log.Printf("Scraper %s: all %d metrics fit again (limit %d, top_k %d)", scraper.Name, len(result), scraper.Limit, scraper.TopK)
+		}
+		return result
+	}
+
+	keys := make([]string, 0, len(result))
+	for key := range result {
+		keys = append(keys, key)
+	}
+
+	if scraper.TopK > 0 {
+		// Highest value first; non-numeric and NaN values sort last
+		sort.Slice(keys, func(i, j int) bool {
+			a, aNum := utils.ToFloat64(result[keys[i]])
+			b, bNum := utils.ToFloat64(result[keys[j]])
+			aNum = aNum && !math.IsNaN(a)
+			bNum = bNum && !math.IsNaN(b)
+			if aNum != bNum {
+				return aNum
+			}
+			if aNum && a != b {
+				return a > b
+			}
+			return keys[i] < keys[j]
+		})
+	} else {
+		sort.Strings(keys)
+	}
+
+	if setLimited(scraper.Name, true) {
+		log.Printf("Scraper %s: keeping %d of %d metrics (limit %d, top_k %d)", scraper.Name, keep, len(result), scraper.Limit, scraper.TopK)
+	}
+
+	limited := make(map[string]interface{}, keep)
+	for _, key := range keys[:keep] {
+		limited[key] = result[key]
+	}
+	return limited
+}
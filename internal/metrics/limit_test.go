@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+func TestLimitMetricsLogsTransitionsOnly(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+	defer setLimited("capped", false)
+
+	scraper := config.ScraperConfig{Name: "capped", Limit: 2}
+	big := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+	small := map[string]interface{}{"a": 1}
+
+	for i := 0; i < 3; i++ {
+		if got := limitMetrics(big, scraper); len(got) != 2 {
+			t.Fatalf("kept %d metrics, want 2", len(got))
+		}
+	}
+	if n := strings.Count(logs.String(), "keeping 2 of 3"); n != 1 {
+		t.Errorf("truncation logged %d times over 3 scrapes, want once:\n%s", n, logs.String())
+	}
+
+	limitMetrics(small, scraper)
+	limitMetrics(small, scraper)
+	if n := strings.Count(logs.String(), "fit again"); n != 1 {
+		t.Errorf("recovery logged %d times, want once:\n%s", n, logs.String())
+	}
+
+	limitMetrics(big, scraper)
+	if n := strings.Count(logs.String(), "keeping 2 of 3"); n != 2 {
+		t.Errorf("renewed truncation not logged:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// MetricMeta describes a metric for display: its unit and a one-line help
+type MetricMeta struct {
+	Unit string
+	Help string
+}
+
+// Built-in descriptions of the system metrics, keyed by default name
+var systemMetricMeta = map[string]MetricMeta{
+	"cpu_usage_percent":          {"percent", "Overall CPU usage"},
+	"cpu_usage_per_core":         {"percent", "Per-core CPU usage"},
+	"cpu_count":                  {"count", "Number of logical CPU cores"},
+	"cpu_count_physical":         {"count", "Number of physical CPU cores"},
+	"cpu_load_1min":              {"load", "1-minute load average"},
+	"cpu_load_5min":              {"load", "5-minute load average"},
+	"cpu_load_15min":             {"load", "15-minute load average"},
+	"cpu_load_1min_per_core":     {"load", "1-minute load average divided by logical cores"},
+	"cpu_load_5min_per_core":     {"load", "5-minute load average divided by logical cores"},
+	"cpu_load_15min_per_core":    {"load", "15-minute load average divided by logical cores"},
+	"cpu_frequency_mhz":          {"megahertz", "Mean current clock speed across cores"},
+	"cpu_frequency_per_core_mhz": {"megahertz", "Current clock speed of each core"},
+
+	"ram_usage_percent":  {"percent", "RAM usage percentage"},
+	"available_ram_mb":   {"megabytes", "Available RAM"},
+	"total_ram_mb":       {"megabytes", "Total RAM"},
+	"ram_cached_mb":      {"megabytes", "RAM used for caching"},
+	"ram_buffers_mb":     {"megabytes", "RAM used for buffers"},
+	"swap_usage_percent": {"percent", "Swap usage percentage"},
+	"swap_total_mb":      {"megabytes", "Total swap space"},
+	"swap_used_mb":       {"megabytes", "Used swap space"},
+
+	"disk_usage_percent":          {"percent", "Disk usage percentage"},
+	"available_disk_gb":           {"gigabytes", "Available disk space"},
+	"total_disk_gb":               {"gigabytes", "Total disk space"},
+	"inode_usage_percent":         {"percent", "Inode usage percentage"},
+	"inodes_total":                {"count", "Total inodes"},
+	"inodes_used":                 {"count", "Used inodes"},
+	"inodes_free":                 {"count", "Free inodes"},
+	"disk_usage_weighted_percent": {"percent", "Used space over total space across all real filesystems"},
+	"disk_read_bytes":             {"bytes", "Cumulative bytes read"},
+	"disk_write_bytes":            {"bytes", "Cumulative bytes written"},
+	"disk_read_bytes_per_sec":     {"bytes_per_second", "Disk read rate"},
+	"disk_write_bytes_per_sec":    {"bytes_per_second", "Disk write rate"},
+	"disk_read_count":             {"count", "Total read operations"},
+	"disk_write_count":            {"count", "Total write operations"},
+
+	"network_bytes_sent":         {"bytes", "Cumulative bytes sent"},
+	"network_bytes_recv":         {"bytes", "Cumulative bytes received"},
+	"network_bytes_sent_per_sec": {"bytes_per_second", "Network send rate"},
+	"network_bytes_recv_per_sec": {"bytes_per_second", "Network receive rate"},
+	"network_packets_sent":       {"count", "Total packets sent"},
+	"network_packets_recv":       {"count", "Total packets received"},
+	"network_errors_in":          {"count", "Inbound network errors"},
+	"network_errors_out":         {"count", "Outbound network errors"},
+	"active_connections":         {"count", "Active network connections"},
+	"listening_ports":            {"", "Listening TCP or bound UDP socket, one series per port"},
+
+	"system_uptime_seconds": {"seconds", "System uptime"},
+	"boot_time_unix":        {"unix_seconds", "System boot time"},
+	"os_platform":           {"", "Operating system platform"},
+	"os_version":            {"", "OS version"},
+	"hostname":              {"", "System hostname"},
+	"kernel_version":        {"", "Kernel version"},
+	"process_count":         {"count", "Number of running processes"},
+	"open_file_descriptors": {"count", "System-wide open file descriptors"},
+	"max_file_descriptors":  {"count", "System-wide file descriptor limit"},
+
+	"battery_percent":  {"percent", "Battery charge, averaged across batteries"},
+	"battery_charging": {"boolean", "1 while the battery is charging"},
+	"power_plugged":    {"boolean", "1 on external power, 0 on battery"},
+	"clock_offset_ms":  {"milliseconds", "Offset of the local clock from the NTP server"},
+}
+
+// Unit names for the byte_unit suffixes
+var byteUnitNames = map[string]string{
+	"bytes": "bytes",
+	"kb":    "kilobytes",
+	"mb":    "megabytes",
+	"gb":    "gigabytes",
+	"tb":    "terabytes",
+}
+
+// SystemMetricMeta returns the unit and help of a system metric key. Labels
+// are ignored, and size metrics renamed by byte_unit (e.g. available_ram_gb)
+// report the unit of their suffix.
+func SystemMetricMeta(key string) (MetricMeta, bool) {
+	name, _ := utils.ParseLabeled(key)
+	if meta, ok := systemMetricMeta[name]; ok {
+		return meta, true
+	}
+
+	idx := strings.LastIndex(name, "_")
+	if idx == -1 || !utils.IsByteUnit(name[idx+1:]) {
+		return MetricMeta{}, false
+	}
+	base := name[:idx]
+	for known, meta := range systemMetricMeta {
+		if i := strings.LastIndex(known, "_"); i != -1 && known[:i] == base && utils.IsByteUnit(known[i+1:]) {
+			return MetricMeta{Unit: byteUnitNames[name[idx+1:]], Help: meta.Help}, true
+		}
+	}
+	return MetricMeta{}, false
+}
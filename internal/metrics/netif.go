@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// Name prefixes of bridge, container and hypervisor interfaces, for
+// platforms without /sys/devices/virtual/net
+var virtualInterfacePrefixes = []string{
+	"docker", "veth", "br-", "virbr", "vnet", "vmnet", "vboxnet",
+	"cni", "flannel", "cali", "weave", "kube-", "lxc", "lxdbr", "podman",
+}
+
+// networkTotals sums I/O counters across interfaces. With
+// exclude_virtual_interfaces, loopback and virtual interfaces are skipped:
+// loopback traffic never leaves the host, and container or bridge traffic
+// is already counted on the physical interface it leaves through.
+func networkTotals(ctx context.Context) (net.IOCountersStat, bool) {
+	if !cfg.System.ExcludeVirtualInterfaces {
+		counters, err := net.IOCountersWithContext(ctx, false)
+		if err != nil || len(counters) == 0 {
+			return net.IOCountersStat{}, false
+		}
+		return counters[0], true
+	}
+
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return net.IOCountersStat{}, false
+	}
+
+	loopback := loopbackInterfaces(ctx)
+	total := net.IOCountersStat{Name: "all"}
+	for _, c := range counters {
+		if loopback[c.Name] || isVirtualInterface(c.Name) {
+			continue
+		}
+		total.BytesSent += c.BytesSent
+		total.BytesRecv += c.BytesRecv
+		total.PacketsSent += c.PacketsSent
+		total.PacketsRecv += c.PacketsRecv
+		total.Errin += c.Errin
+		total.Errout += c.Errout
+		total.Dropin += c.Dropin
+		total.Dropout += c.Dropout
+	}
+	return total, true
+}
+
+// loopbackInterfaces returns the names of interfaces flagged as loopback
+func loopbackInterfaces(ctx context.Context) map[string]bool {
+	loopback := make(map[string]bool)
+	interfaces, err := net.InterfacesWithContext(ctx)
+	if err != nil {
+		// Fall back to the conventional names
+		loopback["lo"] = true
+		loopback["lo0"] = true
+		return loopback
+	}
+	for _, iface := range interfaces {
+		for _, flag := range iface.Flags {
+			if flag == "loopback" {
+				loopback[iface.Name] = true
+			}
+		}
+	}
+	return loopback
+}
+
+// isVirtualInterface reports whether name is a software interface rather
+// than a physical NIC. Linux lists these under /sys/devices/virtual/net;
+// elsewhere common naming conventions are used.
+func isVirtualInterface(name string) bool {
+	if _, err := os.Stat("/sys/devices/virtual/net/" + name); err == nil {
+		return true
+	}
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
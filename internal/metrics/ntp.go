@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	ntpDefaultPort  = "123"
+	ntpTimeout      = 2 * time.Second
+	ntpDefaultCache = time.Hour
+
+	// Seconds between the NTP epoch (1900) and the unix epoch (1970)
+	ntpEpochOffset = 2208988800
+)
+
+// Last measured clock offset. Clock drift is slow, so the server is only
+// queried once per ntp_cache_seconds.
+var (
+	ntpMu       sync.Mutex
+	ntpOffset   time.Duration
+	ntpMeasured time.Time
+	ntpValid    bool
+)
+
+// clockOffset returns the local clock's offset from the configured NTP
+// server, positive when the local clock is behind. The cached value is
+// reused until it expires; a failed query keeps the previous value.
+func clockOffset() (time.Duration, bool) {
+	ttl := ntpDefaultCache
+	if cfg.System.NTPCacheSeconds > 0 {
+		ttl = time.Duration(cfg.System.NTPCacheSeconds) * time.Second
+	}
+
+	ntpMu.Lock()
+	defer ntpMu.Unlock()
+
+	if ntpValid && time.Since(ntpMeasured) < ttl {
+		return ntpOffset, true
+	}
+
+	offset, err := queryNTP(cfg.System.NTPServer)
+	// Don't hammer the server on failure either
+	ntpMeasured = time.Now()
+	if err != nil {
+		log.Printf("WARN: NTP query to %s failed: %v", cfg.System.NTPServer, err)
+		return ntpOffset, ntpValid
+	}
+	ntpOffset = offset
+	ntpValid = true
+	return offset, true
+}
+
+func resetClockOffset() {
+	ntpMu.Lock()
+	ntpValid = false
+	ntpMeasured = time.Time{}
+	ntpMu.Unlock()
+}
+
+// queryNTP sends a single SNTP client request and computes the clock
+// offset as ((t2 - t1) + (t3 - t4)) / 2
+func queryNTP(server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, ntpDefaultPort)
+	}
+
+	conn, err := net.Dial("udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ntpTimeout))
+
+	request := make([]byte, 48)
+	request[0] = 0x23 // LI 0, version 4, mode 3 (client)
+
+	t1 := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	t4 := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	if n < 48 {
+		return 0, fmt.Errorf("short NTP response (%d bytes)", n)
+	}
+	if mode := response[0] & 0x07; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	if stratum := response[1]; stratum == 0 || stratum > 15 {
+		return 0, fmt.Errorf("server is unsynchronized (stratum %d)", stratum)
+	}
+
+	t2 := ntpTime(response[32:40])
+	t3 := ntpTime(response[40:48])
+	return (t2.Sub(t1) + t3.Sub(t4)) / 2, nil
+}
+
+// ntpTime converts a 64-bit NTP timestamp (seconds and fraction since 1900)
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, (fraction*1e9)>>32)
+}
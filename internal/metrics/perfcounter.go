@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// Upper bound on one typeperf run
+const perfCounterTimeout = 10 * time.Second
+
+// Splits \Object(instance)\Counter, with an optional \\HOST prefix
+var counterPathRe = regexp.MustCompile(`^(?:\\\\[^\\]+)?\\([^\\(]+)(?:\((.*)\))?\\(.+)$`)
+
+type counterPath struct {
+	object, instance, counter string
+}
+
+func parseCounterPath(path string) (counterPath, bool) {
+	m := counterPathRe.FindStringSubmatch(strings.TrimSpace(path))
+	if m == nil {
+		return counterPath{}, false
+	}
+	return counterPath{object: m[1], instance: m[2], counter: m[3]}, true
+}
+
+// readPerfCounters samples the configured Windows performance counters
+// once. Each counter maps to a metric name; a counter with a (*) instance
+// yields one series per instance, labeled instance="<name>".
+func readPerfCounters(ctx context.Context, source config.SourceConfig) (map[string]interface{}, error) {
+	if len(source.Counters) == 0 {
+		return nil, fmt.Errorf("perfcounter source requires counters")
+	}
+
+	paths := make([]string, 0, len(source.Counters))
+	for path := range source.Counters {
+		if _, ok := parseCounterPath(path); !ok {
+			return nil, fmt.Errorf("invalid counter path %q, expected \\Object(instance)\\Counter", path)
+		}
+		paths = append(paths, path)
+	}
+
+	output, err := runTypeperf(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	return parseTypeperf(output, source.Counters)
+}
+
+// parseTypeperf maps typeperf's CSV output (a header row of full counter
+// paths, then one row per sample) to metrics, using the last sample that
+// has a value for each column
+func parseTypeperf(output string, counters map[string]string) (map[string]interface{}, error) {
+	// Status lines such as "Exiting, please wait..." aren't CSV records
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), `"`) {
+			lines = append(lines, line)
+		}
+	}
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing typeperf output: %v", err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("typeperf returned no samples")
+	}
+
+	wanted := make(map[counterPath]string, len(counters))
+	for path, name := range counters {
+		if parsed, ok := parseCounterPath(path); ok {
+			wanted[parsed] = name
+		}
+	}
+
+	result := make(map[string]interface{})
+	header := records[0]
+	for col := 1; col < len(header); col++ {
+		column, ok := parseCounterPath(header[col])
+		if !ok {
+			continue
+		}
+		key, ok := counterKey(column, wanted)
+		if !ok {
+			continue
+		}
+		for row := len(records) - 1; row > 0; row-- {
+			if col >= len(records[row]) {
+				continue
+			}
+			if value, err := strconv.ParseFloat(strings.TrimSpace(records[row][col]), 64); err == nil {
+				result[key] = value
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// counterKey returns the metric key for a sampled counter column, matching
+// object and counter names case-insensitively as Windows does
+func counterKey(column counterPath, wanted map[counterPath]string) (string, bool) {
+	for path, name := range wanted {
+		if !strings.EqualFold(path.object, column.object) || !strings.EqualFold(path.counter, column.counter) {
+			continue
+		}
+		if path.instance == "*" {
+			return utils.Labeled(name, "instance", column.instance), true
+		}
+		if strings.EqualFold(path.instance, column.instance) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package metrics
+
+import (
+	"context"
+	"errors"
+)
+
+// runTypeperf is only implemented on Windows
+func runTypeperf(ctx context.Context, paths []string) (string, error) {
+	return "", errors.New("perfcounter sources are only supported on Windows")
+}
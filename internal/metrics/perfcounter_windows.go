@@ -0,0 +1,27 @@
+//go:build windows
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runTypeperf takes one sample of the given counters with the built-in
+// typeperf tool and returns its CSV output
+func runTypeperf(ctx context.Context, paths []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, perfCounterTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "typeperf", append(paths, "-sc", "1")...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("typeperf: %v: %s", err, strings.TrimSpace(stderr.String()+" "+stdout.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// collectListeningPorts emits listening_ports{protocol,address,port} = 1
+// for every TCP socket in LISTEN state and every UDP socket that looks
+// bound by a server, with the owning process as a label when
+// listening_ports_process is set. Alerting on a new series catches a port
+// that unexpectedly opens.
+func collectListeningPorts(ctx context.Context, send func(string, interface{})) {
+	conns, err := net.ConnectionsWithContext(ctx, "inet")
+	if err != nil {
+		return
+	}
+
+	// Process names by PID, looked up once per collection
+	names := make(map[int32]string)
+	processName := func(pid int32) string {
+		if name, ok := names[pid]; ok {
+			return name
+		}
+		var name string
+		if p, err := process.NewProcessWithContext(ctx, pid); err == nil {
+			name, _ = p.NameWithContext(ctx)
+		}
+		names[pid] = name
+		return name
+	}
+
+	ephemeralLow, ephemeralHigh := ephemeralPortRange()
+
+	for _, conn := range conns {
+		var protocol string
+		switch {
+		case conn.Type == syscall.SOCK_STREAM && conn.Status == "LISTEN":
+			protocol = "tcp"
+		case conn.Type == syscall.SOCK_DGRAM && conn.Raddr.Port == 0:
+			// UDP has no LISTEN state, and clients that send without
+			// connecting have no peer either. Their ports come from the
+			// ephemeral range and they rarely bind a specific address, so
+			// a socket matching both is taken for a client.
+			if isWildcardAddr(conn.Laddr.IP) && conn.Laddr.Port >= ephemeralLow && conn.Laddr.Port <= ephemeralHigh {
+				continue
+			}
+			protocol = "udp"
+		default:
+			continue
+		}
+
+		kv := []string{"protocol", protocol, "address", conn.Laddr.IP, "port", strconv.FormatUint(uint64(conn.Laddr.Port), 10)}
+		if cfg.System.ListeningPortsProcess && conn.Pid > 0 {
+			if name := processName(conn.Pid); name != "" {
+				kv = append(kv, "process", name)
+			}
+		}
+		send(utils.Labeled("listening_ports", kv...), 1)
+	}
+}
+
+func isWildcardAddr(ip string) bool {
+	return ip == "" || ip == "*" || ip == "0.0.0.0" || ip == "::"
+}
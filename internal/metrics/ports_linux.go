@@ -0,0 +1,30 @@
+//go:build linux
+
+package metrics
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ephemeralPortRange returns the range the kernel picks client ports from,
+// per /proc/sys/net/ipv4/ip_local_port_range, or Linux's default if it
+// can't be read.
+func ephemeralPortRange() (low, high uint32) {
+	low, high = 32768, 60999
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range")
+	if err != nil {
+		return low, high
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return low, high
+	}
+	l, err1 := strconv.ParseUint(fields[0], 10, 16)
+	h, err2 := strconv.ParseUint(fields[1], 10, 16)
+	if err1 != nil || err2 != nil || l > h {
+		return low, high
+	}
+	return uint32(l), uint32(h)
+}
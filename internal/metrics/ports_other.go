@@ -0,0 +1,9 @@
+//go:build !linux
+
+package metrics
+
+// ephemeralPortRange returns the IANA dynamic port range, the default
+// client port range on Windows, macOS and the BSDs
+func ephemeralPortRange() (low, high uint32) {
+	return 49152, 65535
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+func TestListeningPortsSkipsUDPClients(t *testing.T) {
+	defer func(old *config.Config) { cfg = old }(cfg)
+	cfg = &config.Config{}
+
+	// An unconnected client socket: wildcard address, ephemeral port
+	client, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// A server bound to a specific address
+	server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	seen := make(map[string]bool)
+	collectListeningPorts(context.Background(), func(key string, _ interface{}) {
+		seen[key] = true
+	})
+	if len(seen) == 0 {
+		t.Skip("socket list unavailable")
+	}
+
+	has := func(port int) bool {
+		for key := range seen {
+			if strings.Contains(key, `protocol="udp"`) && strings.Contains(key, `port="`+strconv.Itoa(port)+`"`) {
+				return true
+			}
+		}
+		return false
+	}
+	if port := client.LocalAddr().(*net.UDPAddr).Port; has(port) {
+		t.Errorf("client socket on ephemeral port %d listed as listening", port)
+	}
+	if port := server.LocalAddr().(*net.UDPAddr).Port; !has(port) {
+		t.Errorf("server socket on 127.0.0.1:%d not listed", port)
+	}
+}
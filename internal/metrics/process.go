@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// A configured process name pattern
+type trackedProcess struct {
+	label string
+	re    *regexp.Regexp
+}
+
+// Compiled track_processes patterns, built at Init
+var trackedProcesses []trackedProcess
+
+// CPU time of a tracked process at its last collection
+type processCPUSample struct {
+	at      int64 // unix ns
+	created int64 // process start, to tell a reused PID apart
+	seconds float64
+}
+
+// Last CPU time per tracked PID, so process_cpu_percent is the usage since
+// the previous collection rather than the average over the process's life
+var (
+	processCPU   = make(map[int32]processCPUSample)
+	processCPUMu sync.Mutex
+)
+
+func initProcessTracking() {
+	trackedProcesses = nil
+	for _, pattern := range cfg.System.TrackProcesses {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("WARN: Ignoring invalid track_processes pattern '%s': %v", pattern, err)
+			continue
+		}
+		trackedProcesses = append(trackedProcesses, trackedProcess{label: pattern, re: re})
+	}
+	if len(trackedProcesses) == 0 {
+		processCPUMu.Lock()
+		processCPU = make(map[int32]processCPUSample)
+		processCPUMu.Unlock()
+	}
+}
+
+// processCPUPercent records p's CPU time and returns its usage since the
+// previous collection that saw it. ok is false on the first sighting.
+// A collection cut off by ctx doesn't record, so it can't displace a
+// newer sample.
+func processCPUPercent(ctx context.Context, p *process.Process) (percent float64, ok bool) {
+	times, err := p.TimesWithContext(ctx)
+	if err != nil {
+		return 0, false
+	}
+	created, _ := p.CreateTimeWithContext(ctx)
+	sample := processCPUSample{at: time.Now().UnixNano(), created: created, seconds: times.User + times.System}
+
+	processCPUMu.Lock()
+	defer processCPUMu.Unlock()
+	prev, seen := processCPU[p.Pid]
+	if ctx.Err() != nil || (seen && sample.at <= prev.at) {
+		return 0, false
+	}
+	processCPU[p.Pid] = sample
+
+	if !seen || prev.created != sample.created {
+		return 0, false
+	}
+	return (sample.seconds - prev.seconds) / (float64(sample.at-prev.at) * 1e-9) * 100, true
+}
+
+// pruneProcessCPU forgets PIDs that no longer match any pattern
+func pruneProcessCPU(ctx context.Context, matched map[int32]bool) {
+	processCPUMu.Lock()
+	defer processCPUMu.Unlock()
+	// A cut-off collection may not have seen every process
+	if ctx.Err() != nil {
+		return
+	}
+	for pid := range processCPU {
+		if !matched[pid] {
+			delete(processCPU, pid)
+		}
+	}
+}
+
+// collectTrackedProcesses emits CPU, memory and count for every tracked
+// pattern, aggregated across all processes whose name matches it. CPU is
+// measured between collections, so it is left out until a matching
+// process has been seen twice, and a newly started process counts from
+// its second collection.
+func collectTrackedProcesses(ctx context.Context, send func(string, interface{})) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return
+	}
+
+	type totals struct {
+		count    int
+		cpu      float64
+		measured bool // cpu covers at least one process seen before
+		rss      uint64
+	}
+	groups := make([]totals, len(trackedProcesses))
+	matched := make(map[int32]bool)
+
+	for _, p := range procs {
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+
+		// Read each process once even if several patterns match it
+		var cpuPercent float64
+		var cpuOK, memOK, read bool
+		var rss uint64
+
+		for i, tp := range trackedProcesses {
+			if !tp.re.MatchString(name) {
+				continue
+			}
+			if !read {
+				read = true
+				matched[p.Pid] = true
+				cpuPercent, cpuOK = processCPUPercent(ctx, p)
+				if mem, err := p.MemoryInfoWithContext(ctx); err == nil {
+					rss, memOK = mem.RSS, true
+				}
+			}
+			groups[i].count++
+			if cpuOK {
+				groups[i].cpu += cpuPercent
+				groups[i].measured = true
+			}
+			if memOK {
+				groups[i].rss += rss
+			}
+		}
+	}
+	pruneProcessCPU(ctx, matched)
+
+	for i, tp := range trackedProcesses {
+		send(utils.Labeled("process_count", "name", tp.label), groups[i].count)
+		if groups[i].measured || groups[i].count == 0 {
+			send(utils.Labeled("process_cpu_percent", "name", tp.label), round(groups[i].cpu))
+		}
+		sendSize(send, "process_memory_mb", groups[i].rss, "name", tp.label)
+	}
+}
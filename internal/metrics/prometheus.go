@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes result in the Prometheus text exposition format,
+// one sample per numeric value. Names and labels follow the same rules as
+// remote write; samples carry no timestamp so Prometheus uses scrape time.
+// Metrics from keep_metadata sources are preceded by their # HELP and
+// # TYPE lines.
+func WritePrometheus(w io.Writer, result map[string]interface{}) {
+	series := flattenSeries(result, nil, 0)
+
+	// Sort by name first so every series of a metric stays contiguous
+	sort.Slice(series, func(i, j int) bool {
+		a, b := seriesName(series[i]), seriesName(series[j])
+		if a != b {
+			return a < b
+		}
+		return seriesKey(series[i]) < seriesKey(series[j])
+	})
+
+	families := prometheusFamilies()
+	previous := ""
+	for _, s := range series {
+		if name := seriesName(s); name != previous {
+			previous = name
+			if meta, ok := families[name]; ok {
+				if meta.Help != "" {
+					fmt.Fprintf(w, "# HELP %s %s\n", name, helpEscaper.Replace(meta.Help))
+				}
+				if promTypes[meta.Type] {
+					fmt.Fprintf(w, "# TYPE %s %s\n", name, meta.Type)
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s %s\n", seriesKey(s), strconv.FormatFloat(s.value, 'f', -1, 64))
+	}
+}
+
+// seriesKey renders a series as name{label="value",...}
+func seriesKey(s promSeries) string {
+	var name string
+	var labels []string
+	for _, label := range s.labels {
+		if label.name == "__name__" {
+			name = label.value
+			continue
+		}
+		labels = append(labels, label.name+`="`+escapeLabelValue(label.value)+`"`)
+	}
+	if len(labels) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
+
+func seriesName(s promSeries) string {
+	for _, label := range s.labels {
+		if label.name == "__name__" {
+			return label.value
+		}
+	}
+	return ""
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+// systemSeriesLabels returns the name prefix and labels for system metrics:
+// system.labels, plus the system name as a label when name_label is set, in
+// which case the name is no longer used as a metric name prefix
+func systemSeriesLabels(namespace string) (string, []string) {
+	var kv []string
+	names := make([]string, 0, len(cfg.System.Labels))
+	for name := range cfg.System.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kv = append(kv, name, cfg.System.Labels[name])
+	}
+
+	if cfg.System.NameLabel != "" {
+		return "", append(kv, cfg.System.NameLabel, namespace)
+	}
+	return namespace, kv
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/parsers"
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// # HELP / # TYPE captured from keep_metadata sources, by scraper name and
+// then by output metric name. Replaced on every successful scrape.
+var (
+	scrapedMeta   = make(map[string]map[string]parsers.PromMetadata)
+	scrapedMetaMu sync.RWMutex
+)
+
+// ScrapedMetadata returns the Prometheus metadata captured on the last
+// scrape of the named scraper, keyed by output metric name. The map must
+// not be modified.
+func ScrapedMetadata(scraper string) map[string]parsers.PromMetadata {
+	scrapedMetaMu.RLock()
+	defer scrapedMetaMu.RUnlock()
+	return scrapedMeta[scraper]
+}
+
+func storeScrapedMetadata(scraper string, meta map[string]parsers.PromMetadata) {
+	scrapedMetaMu.Lock()
+	defer scrapedMetaMu.Unlock()
+	if len(meta) == 0 {
+		delete(scrapedMeta, scraper)
+		return
+	}
+	scrapedMeta[scraper] = meta
+}
+
+// outputMetadata follows families through a source's metric maps, giving
+// the metadata of every output metric in result that came from a family.
+// origins maps auto_map output names back to their source keys.
+func outputMetadata(scraper config.ScraperConfig, families map[string]parsers.PromMetadata, origins map[string]string, result map[string]interface{}) map[string]parsers.PromMetadata {
+	out := make(map[string]parsers.PromMetadata)
+	for name, source := range origins {
+		if meta, ok := familyOf(families, source); ok {
+			out[name] = meta
+		}
+	}
+
+	for _, metricMap := range scraper.Metrics {
+		if metricMap.Match != "" && strings.ContainsAny(metricMap.Match, "*?[") {
+			for key := range families {
+				if matched, _ := path.Match(metricMap.Match, key); matched {
+					out[metricMap.Name+"_"+key] = families[key]
+				}
+			}
+			continue
+		}
+		source := metricMap.Match
+		if source == "" {
+			source = metricMap.Path
+		}
+		if meta, ok := familyOf(families, source); ok {
+			out[metricMap.Name] = meta
+		}
+	}
+
+	return keepPresent(out, result)
+}
+
+// familyOf looks up the family of a sample name, allowing for the _total
+// suffix OpenMetrics counters add to their family name
+func familyOf(families map[string]parsers.PromMetadata, name string) (parsers.PromMetadata, bool) {
+	if meta, ok := families[name]; ok {
+		return meta, true
+	}
+	meta, ok := families[strings.TrimSuffix(name, "_total")]
+	return meta, ok
+}
+
+// keepPresent drops metadata for names no longer in result, e.g. after
+// name_style or relabel renamed them
+func keepPresent(meta map[string]parsers.PromMetadata, result map[string]interface{}) map[string]parsers.PromMetadata {
+	names := make(map[string]bool, len(result))
+	for key := range result {
+		name, _ := utils.ParseLabeled(key)
+		names[name] = true
+	}
+	for name := range meta {
+		if !names[name] {
+			delete(meta, name)
+		}
+	}
+	return meta
+}
+
+// prometheusFamilies maps Prometheus series names to their captured
+// metadata, prefixing each scraper's names the way its namespace places
+// them in the output
+func prometheusFamilies() map[string]parsers.PromMetadata {
+	families := make(map[string]parsers.PromMetadata)
+	for _, scraper := range cfg.Scrapers {
+		prefix := scraper.Name + "_"
+		switch scraper.Namespace {
+		case "", "nested":
+		case "flat":
+			prefix = ""
+		default:
+			prefix = scraper.Namespace + "_"
+		}
+		for name, meta := range ScrapedMetadata(scraper.Name) {
+			families[promNameUnsafe.ReplaceAllString(prefix+name, "_")] = meta
+		}
+	}
+	return families
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// Types that still describe the re-emitted samples. The parser drops
+// labels, so histogram and summary samples are written without a TYPE.
+var promTypes = map[string]bool{"counter": true, "gauge": true, "untyped": true}
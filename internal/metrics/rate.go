@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// Most samples kept per counter. Enough for a window of several minutes at
+// the default cache TTL; older samples are dropped first.
+const maxRateSamples = 128
+
+// Period *_per_sec rates are averaged over; 0 uses the gap since the
+// previous collection. Set at Init.
+var rateWindow time.Duration
+
+type counterSample struct {
+	at    int64 // unix ns
+	value uint64
+}
+
+// rateCounter keeps recent samples of a monotonically increasing counter
+// so rates can be computed over a fixed window instead of between the last
+// two collections, which vary with cache expiry and request timing.
+type rateCounter struct {
+	mu      sync.Mutex
+	samples []counterSample
+}
+
+// Counters behind the *_per_sec metrics
+var (
+	diskReadRate  rateCounter
+	diskWriteRate rateCounter
+	netSentRate   rateCounter
+	netRecvRate   rateCounter
+)
+
+// observe records a sample and returns the per-second rate since the
+// newest sample at least rateWindow old, or the oldest one kept when
+// the window isn't filled yet. ok is false until there are two samples.
+// A sample no newer than the last one, e.g. from a collector that
+// outlived its collection, is dropped.
+func (c *rateCounter) observe(now int64, value uint64) (rate float64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n := len(c.samples); n > 0 && now <= c.samples[n-1].at {
+		return 0, false
+	}
+
+	// A counter that went backwards (reboot, interface reset) starts over
+	if n := len(c.samples); n > 0 && value < c.samples[n-1].value {
+		c.samples = c.samples[:0]
+	}
+
+	cutoff := now - int64(rateWindow)
+	drop := 0
+	for drop+1 < len(c.samples) && c.samples[drop+1].at <= cutoff {
+		drop++
+	}
+	if len(c.samples)-drop >= maxRateSamples {
+		drop = len(c.samples) - maxRateSamples + 1
+	}
+	c.samples = append(c.samples[:0], c.samples[drop:]...)
+	c.samples = append(c.samples, counterSample{at: now, value: value})
+
+	if len(c.samples) < 2 {
+		return 0, false
+	}
+	base := c.samples[0]
+	elapsed := float64(now-base.at) * 1e-9
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return float64(value-base.value) / elapsed, true
+}
+
+func (c *rateCounter) reset() {
+	c.mu.Lock()
+	c.samples = nil
+	c.mu.Unlock()
+}
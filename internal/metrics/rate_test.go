@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// fakeDiskCounters returns a disk counter source that grows by step bytes
+// read and written on every call
+func fakeDiskCounters(step uint64) func(context.Context) (map[string]disk.IOCountersStat, error) {
+	var mu sync.Mutex
+	var total uint64
+	return func(context.Context) (map[string]disk.IOCountersStat, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		total += step
+		return map[string]disk.IOCountersStat{
+			"sda": {Name: "sda", ReadBytes: total, WriteBytes: total},
+		}, nil
+	}
+}
+
+func TestFirstRateAfterInit(t *testing.T) {
+	const step = 1 << 20
+	original := diskIOCounters
+	diskIOCounters = fakeDiskCounters(step)
+	defer func() { diskIOCounters = original }()
+
+	Init(&config.Config{System: config.SystemConfig{
+		Enabled: true,
+		Metrics: []string{"disk_read_bytes_per_sec", "disk_write_bytes_per_sec"},
+	}})
+	defer Shutdown(context.Background())
+
+	// Init primed the counters; the first collection spans at least this
+	const interval = 50 * time.Millisecond
+	start := time.Now()
+	time.Sleep(interval)
+
+	result, _, err := CollectSystem(context.Background())
+	if err != nil {
+		t.Fatalf("CollectSystem: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	for _, key := range []string{"disk_read_bytes_per_sec", "disk_write_bytes_per_sec"} {
+		value, ok := result[key]
+		if !ok {
+			t.Fatalf("%s missing from the first collection: %v", key, result)
+		}
+		rate, ok := value.(float64)
+		if !ok {
+			t.Fatalf("%s = %#v, want a float64", key, value)
+		}
+		// One step of growth since priming, over at least interval and at most elapsed
+		if min, max := step/elapsed.Seconds(), step/interval.Seconds(); rate < min || rate > max {
+			t.Errorf("%s = %v, want between %v and %v", key, rate, min, max)
+		}
+	}
+}
+
+func TestObserveDropsOutOfOrderSamples(t *testing.T) {
+	rateWindow = 0
+	var c rateCounter
+	second := int64(time.Second)
+
+	c.observe(10*second, 1000)
+	if rate, ok := c.observe(12*second, 3000); !ok || rate != 1000 {
+		t.Fatalf("rate = %v, %v, want 1000, true", rate, ok)
+	}
+
+	// A late sample from an earlier collection, with a lower counter
+	// value, must neither reset the history nor produce a rate
+	if _, ok := c.observe(11*second, 2000); ok {
+		t.Error("older sample produced a rate")
+	}
+	if _, ok := c.observe(12*second, 3000); ok {
+		t.Error("sample at the same time produced a rate")
+	}
+	if rate, ok := c.observe(14*second, 5000); !ok || rate != 1000 {
+		t.Errorf("rate after dropped samples = %v, %v, want 1000, true", rate, ok)
+	}
+}
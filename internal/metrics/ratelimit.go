@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tokenBucket spaces out callers to a steady rate, letting up to burst
+// through at once after an idle period
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	seq    uint64 // reservations taken so far, to tell the newest one
+}
+
+// Shared by every url fetch; nil when max_scrapes_per_second is unset.
+// Swapped on reload while fetches may be waiting on the old one.
+var outboundLimiter atomic.Pointer[tokenBucket]
+
+// initOutboundLimit sets up the global fetch limiter. The burst is a
+// single token, so fetches are spaced evenly at 1/perSecond instead of
+// arriving in bursts that could trip a per-account quota.
+func initOutboundLimit(perSecond float64) {
+	if perSecond <= 0 {
+		outboundLimiter.Store(nil)
+		return
+	}
+	outboundLimiter.Store(&tokenBucket{rate: perSecond, burst: 1, tokens: 1, last: time.Now()})
+}
+
+// waitOutbound blocks until the global limiter allows another fetch, or
+// returns ctx's error if ctx ends first
+func waitOutbound(ctx context.Context) error {
+	if limiter := outboundLimiter.Load(); limiter != nil {
+		return limiter.wait(ctx)
+	}
+	return nil
+}
+
+// wait takes a token, blocking until it is due. Tokens are reserved under
+// the lock, so concurrent callers queue in arrival order instead of
+// racing for each refill. A caller whose ctx ends while queued returns
+// ctx's error; its token goes back only if nobody queued behind it, since
+// a refund ahead of later callers would let a new arrival skip them.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	b.seq++
+	seq := b.seq
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		if b.seq == seq {
+			b.tokens++
+		}
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketCancelKeepsQueueOrder(t *testing.T) {
+	// One token every 10s, so nothing queued is released during the test
+	b := &tokenBucket{rate: 0.1, burst: 1, tokens: 1, last: time.Now()}
+	if err := b.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := func() (context.CancelFunc, chan error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		seq := b.reservations()
+		go func() { done <- b.wait(ctx) }()
+		for b.reservations() == seq {
+			time.Sleep(time.Millisecond)
+		}
+		return cancel, done
+	}
+	cancelMiddle, middle := queue()
+	cancelLast, last := queue()
+
+	// Someone queued behind: the reservation stays, or a newcomer could
+	// take it ahead of them
+	cancelMiddle()
+	if err := <-middle; !errors.Is(err, context.Canceled) {
+		t.Fatalf("middle caller got %v", err)
+	}
+	if tokens := b.currentTokens(); tokens > -1.9 {
+		t.Errorf("tokens = %.2f after a mid-queue cancel, want the reservation kept (about -2)", tokens)
+	}
+
+	// The newest caller leaving undoes its own reservation
+	cancelLast()
+	if err := <-last; !errors.Is(err, context.Canceled) {
+		t.Fatalf("last caller got %v", err)
+	}
+	if tokens := b.currentTokens(); tokens < -1.1 || tokens > -0.9 {
+		t.Errorf("tokens = %.2f after the last caller cancelled, want about -1", tokens)
+	}
+}
+
+func (b *tokenBucket) reservations() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+func (b *tokenBucket) currentTokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// Upper bound on each reachability check
+const reachTimeout = 3 * time.Second
+
+// errNotChecked marks sources with no cheap connectivity check
+var errNotChecked = errors.New("not checked")
+
+// Reachability is the result of a lightweight connectivity check of one
+// scraper's sources
+type Reachability struct {
+	Reachable bool    `json:"reachable"`
+	Checked   bool    `json:"checked"` // false when no source type supports a check
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// CheckReachability checks that each of the scraper's sources can be
+// reached without fetching anything: a HEAD request for url sources, a
+// stat for files and FIFOs, and a TCP connect for ssh and networked sql
+// sources. Any HTTP response counts as reachable. SNMP, perfcounter and
+// other sources without a cheap check are skipped.
+func CheckReachability(scraper config.ScraperConfig) Reachability {
+	ctx, cancel := context.WithTimeout(context.Background(), reachTimeout)
+	defer cancel()
+
+	start := time.Now()
+	report := Reachability{Reachable: true}
+	for _, sub := range expandSources(scraper) {
+		err := checkSource(ctx, sub.Source)
+		if err == errNotChecked {
+			continue
+		}
+		report.Checked = true
+		if err != nil {
+			report.Reachable = false
+			if len(scraper.Sources) > 0 {
+				err = fmt.Errorf("source %s: %w", sourceName(sub.Source), err)
+			}
+			report.Error = err.Error()
+			break
+		}
+	}
+	report.LatencyMs = durationMs(time.Since(start))
+	return report
+}
+
+func checkSource(ctx context.Context, source config.SourceConfig) error {
+	switch source.Type {
+	case "url":
+		rendered, err := renderSource(source)
+		if err != nil {
+			return err
+		}
+		err = headURL(ctx, rendered, rendered.URL)
+		if err != nil && rendered.FallbackURL != "" {
+			err = headURL(ctx, rendered, rendered.FallbackURL)
+		}
+		return err
+	case "file", "fifo":
+		_, err := os.Stat(source.Path)
+		return err
+	case "ssh":
+		port := source.SSHPort
+		if port == 0 {
+			port = 22
+		}
+		return dialTCP(ctx, net.JoinHostPort(source.SSHHost, strconv.Itoa(port)))
+	case "sql":
+		switch source.Driver {
+		case "sqlite", "sqlite3":
+			_, err := os.Stat(source.DSN)
+			return err
+		case "postgres", "postgresql", "mysql":
+			u, err := url.Parse(source.DSN)
+			if err != nil || u.Host == "" {
+				return errNotChecked
+			}
+			host := u.Host
+			if u.Port() == "" {
+				port := "5432"
+				if source.Driver == "mysql" {
+					port = "3306"
+				}
+				host = net.JoinHostPort(u.Hostname(), port)
+			}
+			return dialTCP(ctx, host)
+		}
+	}
+	return errNotChecked
+}
+
+func headURL(ctx context.Context, source config.SourceConfig, target string) error {
+	client, err := clientFor(source)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func dialTCP(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// Pseudo-label addressing the series name in relabel rules
+const nameLabel = "__name__"
+
+type relabelRule struct {
+	config.RelabelConfig
+	re *regexp.Regexp
+}
+
+// A scraper's relabel rules, compiled at Init. err is the first invalid
+// rule, which fails every scrape of the scraper.
+type compiledRelabel struct {
+	rules []relabelRule
+	err   error
+}
+
+// Compiled relabel rules by scraper name, built at Init
+var relabelRules map[string]compiledRelabel
+
+func initRelabelRules(scrapers []config.ScraperConfig) {
+	relabelRules = make(map[string]compiledRelabel, len(scrapers))
+	for _, scraper := range scrapers {
+		if len(scraper.Relabel) == 0 {
+			continue
+		}
+		rules, err := compileRelabel(scraper.Relabel)
+		if err != nil {
+			log.Printf("WARN: Scraper %s: %v; its scrapes will fail until the config is fixed", scraper.Name, err)
+		}
+		relabelRules[scraper.Name] = compiledRelabel{rules: rules, err: err}
+	}
+}
+
+func compileRelabel(rules []config.RelabelConfig) ([]relabelRule, error) {
+	compiled := make([]relabelRule, len(rules))
+	for i, rule := range rules {
+		pattern := rule.Regex
+		if pattern == "" {
+			pattern = "(.*)"
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid relabel regex %q: %v", rule.Regex, err)
+		}
+		switch rule.Action {
+		case "", "replace", "lowercase", "uppercase", "keep", "drop":
+		default:
+			return nil, fmt.Errorf("unknown relabel action: %s", rule.Action)
+		}
+		compiled[i] = relabelRule{RelabelConfig: rule, re: re}
+	}
+	return compiled, nil
+}
+
+// relabel applies the scraper's rules in order to every series key in
+// result. Series whose name ends up empty, or that a keep/drop rule
+// rejects, are removed.
+func relabel(result map[string]interface{}, scraper string) (map[string]interface{}, error) {
+	compiled, ok := relabelRules[scraper]
+	if !ok {
+		return result, nil
+	}
+	if compiled.err != nil {
+		return nil, compiled.err
+	}
+
+	out := make(map[string]interface{}, len(result))
+	for key, value := range result {
+		name, kv := utils.ParseLabeled(key)
+		keep := true
+		for _, rule := range compiled.rules {
+			if name, kv, keep = applyRelabel(rule, name, kv); !keep {
+				break
+			}
+		}
+		if keep && name != "" {
+			out[utils.Labeled(name, kv...)] = value
+		}
+	}
+	return out, nil
+}
+
+// applyRelabel runs one rule against a series, returning its new name and
+// labels and whether it survives
+func applyRelabel(rule relabelRule, name string, kv []string) (string, []string, bool) {
+	source := rule.Source
+	if source == "" {
+		source = nameLabel
+	}
+	value := labelValue(name, kv, source)
+
+	var replaced string
+	switch rule.Action {
+	case "keep":
+		return name, kv, rule.re.MatchString(value)
+	case "drop":
+		return name, kv, !rule.re.MatchString(value)
+	case "lowercase":
+		replaced = strings.ToLower(value)
+	case "uppercase":
+		replaced = strings.ToUpper(value)
+	default:
+		match := rule.re.FindStringSubmatchIndex(value)
+		if match == nil {
+			return name, kv, true
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = "$1"
+		}
+		replaced = string(rule.re.ExpandString(nil, replacement, value, match))
+	}
+
+	target := rule.TargetLabel
+	if target == "" {
+		target = nameLabel
+	}
+	if target == nameLabel {
+		return replaced, kv, true
+	}
+	return name, setLabel(kv, target, replaced), true
+}
+
+func labelValue(name string, kv []string, label string) string {
+	if label == nameLabel {
+		return name
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i] == label {
+			return kv[i+1]
+		}
+	}
+	return ""
+}
+
+// setLabel sets or replaces a label; an empty value removes it
+func setLabel(kv []string, label, value string) []string {
+	out := make([]string, 0, len(kv)+2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i] != label {
+			out = append(out, kv[i], kv[i+1])
+		}
+	}
+	if value != "" {
+		out = append(out, label, value)
+	}
+	return out
+}
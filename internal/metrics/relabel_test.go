@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+func TestRelabel(t *testing.T) {
+	initRelabelRules([]config.ScraperConfig{{
+		Name: "app",
+		Relabel: []config.RelabelConfig{
+			{Action: "lowercase"},
+			{Regex: ".*_debug_.*", Action: "drop"},
+			{Regex: "(.*)", Replacement: "myapp_$1"},
+			{Regex: `myapp_(\w+?)_.*`, Replacement: "$1", TargetLabel: "subsystem"},
+		},
+	}})
+	defer initRelabelRules(nil)
+
+	got, err := relabel(map[string]interface{}{
+		"DB_Queries":       1,
+		"cache_debug_hits": 2,
+	}, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{`myapp_db_queries{subsystem="db"}`: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("relabel = %v, want %v", got, want)
+	}
+
+	// Scrapers without rules pass through
+	in := map[string]interface{}{"Up": 1}
+	if got, err := relabel(in, "other"); err != nil || !reflect.DeepEqual(got, in) {
+		t.Errorf("relabel without rules = %v, %v", got, err)
+	}
+}
+
+func TestRelabelInvalidRules(t *testing.T) {
+	initRelabelRules([]config.ScraperConfig{
+		{Name: "bad_regex", Relabel: []config.RelabelConfig{{Regex: "("}}},
+		{Name: "bad_action", Relabel: []config.RelabelConfig{{Action: "hashmod"}}},
+	})
+	defer initRelabelRules(nil)
+
+	for _, name := range []string{"bad_regex", "bad_action"} {
+		if relabelRules[name].err == nil {
+			t.Errorf("%s: invalid rule not reported at load", name)
+		}
+		if _, err := relabel(map[string]interface{}{"up": 1}, name); err == nil {
+			t.Errorf("%s: scrape did not fail", name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// Characters Prometheus doesn't accept in a metric name
+var promNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_:]+`)
+
+type promLabel struct {
+	name, value string
+}
+
+type promSeries struct {
+	labels    []promLabel
+	value     float64
+	timestamp int64 // unix ms
+}
+
+// StartRemoteWrite pushes the output of collect to a Prometheus
+// remote-write endpoint every interval until Shutdown.
+func StartRemoteWrite(rw config.RemoteWriteConfig, collect func() map[string]interface{}) {
+	interval := 15 * time.Second
+	if rw.IntervalSeconds > 0 {
+		interval = time.Duration(rw.IntervalSeconds) * time.Second
+	}
+
+	external := make(map[string]string, len(rw.Labels)+1)
+	if hostname, err := os.Hostname(); err == nil {
+		external["instance"] = hostname
+	}
+	for name, value := range rw.Labels {
+		external[name] = value
+	}
+
+	log.Printf("Remote write enabled: pushing to %s every %s", rw.URL, interval)
+	goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				series := flattenSeries(collect(), external, time.Now().UnixMilli())
+				if err := pushRemoteWrite(ctx, rw, series); err != nil {
+					log.Printf("WARN: Remote write to %s failed: %v", rw.URL, err)
+				}
+			}
+		}
+	})
+}
+
+func pushRemoteWrite(ctx context.Context, rw config.RemoteWriteConfig, series []promSeries) error {
+	body := snappyEncode(encodeWriteRequest(series))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rw.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "probestyx")
+	if rw.Username != "" {
+		req.SetBasicAuth(rw.Username, rw.Password)
+	}
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// flattenSeries turns the nested response map into one series per numeric
+// value. Nested keys are joined with "_" to form the metric name and
+// labeled keys such as `disk_usage_percent{mount="/"}` keep their labels.
+// System metrics also get the configured system labels.
+func flattenSeries(result map[string]interface{}, external map[string]string, now int64) []promSeries {
+	systemName := cfg.System.Name
+	if systemName == "" {
+		systemName = "system"
+	}
+
+	var series []promSeries
+	for key, value := range result {
+		if key == systemName && cfg.System.Enabled {
+			name, kv := systemSeriesLabels(key)
+			collectSeries(&series, value, name, kv, external, now)
+			continue
+		}
+		collectSeries(&series, map[string]interface{}{key: value}, "", nil, external, now)
+	}
+	return series
+}
+
+func collectSeries(series *[]promSeries, value interface{}, name string, kv []string, external map[string]string, now int64) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// Timestamp-wrapped values keep their own collection time
+		if inner, ok := v["value"]; ok && len(v) == 2 {
+			if ts, ok := utils.ToFloat64(v["timestamp"]); ok {
+				collectSeries(series, inner, name, kv, external, int64(ts))
+				return
+			}
+		}
+		for key, inner := range v {
+			keyName, keyLabels := utils.ParseLabeled(key)
+			childName := keyName
+			if name != "" {
+				childName = name + "_" + keyName
+			}
+			collectSeries(series, inner, childName, append(append([]string(nil), kv...), keyLabels...), external, now)
+		}
+	case []float64:
+		for i, inner := range v {
+			collectSeries(series, inner, name, append(append([]string(nil), kv...), "index", fmt.Sprint(i)), external, now)
+		}
+	case []interface{}:
+		for i, inner := range v {
+			collectSeries(series, inner, name, append(append([]string(nil), kv...), "index", fmt.Sprint(i)), external, now)
+		}
+	default:
+		if !utils.IsNumber(v) {
+			return
+		}
+		num, _ := utils.ToFloat64(v)
+		if math.IsNaN(num) {
+			return
+		}
+
+		labelMap := make(map[string]string, len(external)+len(kv)/2+1)
+		for label, labelValue := range external {
+			labelMap[label] = labelValue
+		}
+		for i := 0; i+1 < len(kv); i += 2 {
+			labelMap[promNameUnsafe.ReplaceAllString(kv[i], "_")] = kv[i+1]
+		}
+		labelMap["__name__"] = promNameUnsafe.ReplaceAllString(name, "_")
+
+		labels := make([]promLabel, 0, len(labelMap))
+		for label, labelValue := range labelMap {
+			labels = append(labels, promLabel{label, labelValue})
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+		*series = append(*series, promSeries{labels: labels, value: num, timestamp: now})
+	}
+}
+
+// encodeWriteRequest serializes series as a prometheus.WriteRequest:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+//
+// Like proto3 marshalers, and so byte for byte like prompb, fields holding
+// their zero value are left out.
+func encodeWriteRequest(series []promSeries) []byte {
+	var out []byte
+	for _, s := range series {
+		var ts []byte
+		for _, label := range s.labels {
+			var l []byte
+			if label.name != "" {
+				l = protoBytes(l, 1, []byte(label.name))
+			}
+			if label.value != "" {
+				l = protoBytes(l, 2, []byte(label.value))
+			}
+			ts = protoBytes(ts, 1, l)
+		}
+
+		var sample []byte
+		if s.value != 0 {
+			sample = append(sample, 1<<3|1) // field 1, fixed64
+			sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(s.value))
+		}
+		if s.timestamp != 0 {
+			sample = append(sample, 2<<3|0) // field 2, varint
+			sample = binary.AppendUvarint(sample, uint64(s.timestamp))
+		}
+		ts = protoBytes(ts, 2, sample)
+
+		out = protoBytes(out, 1, ts)
+	}
+	return out
+}
+
+// protoBytes appends a length-delimited field
+func protoBytes(dst []byte, field int, value []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(field)<<3|2)
+	dst = binary.AppendUvarint(dst, uint64(len(value)))
+	return append(dst, value...)
+}
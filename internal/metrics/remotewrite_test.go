@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Expected bytes follow prompb's generated marshaler: fields in number
+// order, zero-valued scalars and empty strings left out, and every
+// sample written even when it is empty
+func TestEncodeWriteRequest(t *testing.T) {
+	name := []byte{0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_'}
+	nameA := append(append([]byte{0x0a, 0x0d}, name...), 0x12, 0x01, 'a')
+
+	tests := []struct {
+		name   string
+		series []promSeries
+		want   []byte
+	}{
+		{"empty", nil, nil},
+		{
+			"one series",
+			[]promSeries{{labels: []promLabel{{"__name__", "up"}, {"job", "x"}}, value: 1, timestamp: 1000}},
+			concat(
+				[]byte{0x0a, 0x28},
+				[]byte{0x0a, 0x0e}, name, []byte{0x12, 0x02, 'u', 'p'},
+				[]byte{0x0a, 0x08, 0x0a, 0x03, 'j', 'o', 'b', 0x12, 0x01, 'x'},
+				[]byte{0x12, 0x0c, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, 0x10, 0xe8, 0x07},
+			),
+		},
+		{
+			"zero value and timestamp",
+			[]promSeries{{labels: []promLabel{{"__name__", "a"}}}},
+			concat([]byte{0x0a, 0x11}, nameA, []byte{0x12, 0x00}),
+		},
+		{
+			"empty label value",
+			[]promSeries{{labels: []promLabel{{"__name__", "a"}, {"env", ""}}}},
+			concat([]byte{0x0a, 0x18}, nameA, []byte{0x0a, 0x05, 0x0a, 0x03, 'e', 'n', 'v'}, []byte{0x12, 0x00}),
+		},
+		{
+			"negative value and timestamp",
+			[]promSeries{{labels: []promLabel{{"__name__", "a"}}, value: -2, timestamp: -1}},
+			concat(
+				[]byte{0x0a, 0x25},
+				nameA,
+				[]byte{0x12, 0x14, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0},
+				[]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+			),
+		},
+		{
+			"two series",
+			[]promSeries{
+				{labels: []promLabel{{"__name__", "a"}}},
+				{labels: []promLabel{{"__name__", "a"}}},
+			},
+			concat([]byte{0x0a, 0x11}, nameA, []byte{0x12, 0x00}, []byte{0x0a, 0x11}, nameA, []byte{0x12, 0x00}),
+		},
+	}
+
+	for _, tt := range tests {
+		if got := encodeWriteRequest(tt.series); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s:\n got % x\nwant % x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
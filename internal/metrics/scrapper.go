@@ -1,10 +1,16 @@
 package metrics
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"path"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +19,9 @@ import (
 	"github.com/devatlogstyx/probestyx/internal/utils"
 )
 
+// Default cap on a source body read into memory
+const defaultMaxResponseBytes = 10 * 1024 * 1024 // 10MB
+
 // Shared HTTP client - created once
 var (
 	httpClient *http.Client
@@ -33,41 +42,133 @@ func getHTTPClient() *http.Client {
 	return httpClient
 }
 
-func CollectScraper(scraper config.ScraperConfig) (map[string]interface{}, error) {
+// CollectScraper scrapes one source. Concurrent calls for the same scraper
+// share a single scrape, each getting its own copy of the result. Fetches
+// are cancelled once ctx is done for every caller sharing them.
+func CollectScraper(ctx context.Context, scraper config.ScraperConfig) (map[string]interface{}, error) {
+	return shareScrape(ctx, scraper.Name, func(ctx context.Context) (map[string]interface{}, error) {
+		result, err := collectScraper(ctx, scraper)
+		recordScrapeResult(scraper, err)
+		return result, err
+	})
+}
+
+func collectScraper(ctx context.Context, scraper config.ScraperConfig) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	meta := make(map[string]parsers.PromMetadata)
+	for _, sub := range expandSources(scraper) {
+		subResult, subMeta, err := scrapeSource(ctx, sub)
+		if err != nil {
+			if len(scraper.Sources) > 0 {
+				return nil, fmt.Errorf("source %s: %w", sourceName(sub.Source), err)
+			}
+			return nil, err
+		}
+		// Later sources win name collisions
+		for name, value := range subResult {
+			result[name] = value
+		}
+		for name, m := range subMeta {
+			meta[name] = m
+		}
+	}
+
+	if scraper.NameStyle != "" && scraper.NameStyle != "as-is" {
+		result = styleNames(result, scraper.NameStyle)
+	}
+
+	result, err := relabel(result, scraper.Name)
+	if err != nil {
+		return nil, err
+	}
+	result = limitMetrics(result, scraper)
+	storeScrapedMetadata(scraper.Name, keepPresent(meta, result))
+	return result, nil
+}
+
+// scrapeSource fetches, parses and maps a single source's metrics, along
+// with their Prometheus metadata when keep_metadata is set
+func scrapeSource(ctx context.Context, scraper config.ScraperConfig) (map[string]interface{}, map[string]parsers.PromMetadata, error) {
 	var rawData string
+	var headers http.Header
+	var parsed map[string]interface{}
+	var families map[string]parsers.PromMetadata
 	var err error
 
 	// Fetch data based on source type
 	switch scraper.Source.Type {
 	case "url":
-		rawData, err = fetchURL(scraper.Source.URL)
+		source, e := renderSource(scraper.Source)
+		if e != nil {
+			return nil, nil, e
+		}
+		rawData, headers, err = fetchURLWithRetries(ctx, scraper.Name, source, source.URL)
+		if err != nil && source.FallbackURL != "" && ctx.Err() == nil {
+			log.Printf("Scraper %s: primary source %s failed: %v, trying fallback %s", scraper.Name, source.URL, err, source.FallbackURL)
+			rawData, headers, err = fetchURLWithRetries(ctx, scraper.Name, source, source.FallbackURL)
+			if err == nil {
+				log.Printf("Scraper %s: using fallback source %s", scraper.Name, source.FallbackURL)
+			}
+		}
 	case "file":
-		data, e := os.ReadFile(scraper.Source.Path)
-		rawData = string(data)
-		err = e
+		// A FIFO without a writer would block a regular read forever
+		if info, e := os.Stat(scraper.Source.Path); e == nil && info.Mode()&os.ModeNamedPipe != 0 {
+			rawData, err = readFIFO(scraper.Source.Path, maxResponseBytes(scraper.Source))
+			break
+		}
+		rawData, err = readFile(scraper.Source.Path, maxResponseBytes(scraper.Source))
+	case "fifo":
+		rawData, err = readFIFO(scraper.Source.Path, maxResponseBytes(scraper.Source))
+	case "ssh":
+		rawData, err = readSSH(ctx, scraper.Source, maxResponseBytes(scraper.Source))
+	case "snmp":
+		// SNMP values arrive already keyed by metric name; there is no
+		// text body to parse
+		parsed, err = getSNMP(scraper.Source)
+	case "sql":
+		parsed, err = querySQL(ctx, scraper.Source)
+	case "perfcounter":
+		parsed, err = readPerfCounters(ctx, scraper.Source)
 	default:
-		return nil, fmt.Errorf("unknown source type: %s", scraper.Source.Type)
+		return nil, nil, fmt.Errorf("unknown source type: %s", scraper.Source.Type)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Parse based on format
-	var parsed map[string]interface{}
-	switch scraper.Source.Format {
-	case "json":
-		parsed, err = parsers.ParseJSON(rawData)
-	case "prometheus":
-		parsed, err = parsers.ParsePrometheus(rawData)
-	case "raw":
-		parsed, err = parsers.ParseRaw(rawData, scraper.Source.Pattern)
-	default:
-		return nil, fmt.Errorf("unknown format: %s", scraper.Source.Format)
-	}
+	if parsed == nil {
+		format := scraper.Source.Format
+		if format == "auto" {
+			format = parsers.DetectFormat(headers.Get("Content-Type"), rawData)
+		}
 
-	if err != nil {
-		return nil, err
+		switch format {
+		case "json":
+			if scraper.Source.UseNumber {
+				parsed, err = parsers.ParseJSONNumbers(rawData)
+			} else {
+				parsed, err = parsers.ParseJSON(rawData)
+			}
+		case "ndjson":
+			parsed, err = parsers.ParseNDJSON(rawData, scraper.Source.KeyField)
+		case "expvar":
+			parsed, err = parsers.ParseExpvar(rawData)
+		case "prometheus":
+			parsed, err = parsers.ParsePrometheus(rawData)
+			if scraper.Source.KeepMetadata {
+				families = parsers.ParsePrometheusMetadata(rawData)
+			}
+		case "raw":
+			parsed, err = parsers.ParseRaw(rawData, scraper.Source)
+		default:
+			return nil, nil, fmt.Errorf("unknown format: %s", scraper.Source.Format)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Apply filters if specified
@@ -77,7 +178,54 @@ func CollectScraper(scraper config.ScraperConfig) (map[string]interface{}, error
 
 	// Map and transform metrics
 	result := make(map[string]interface{})
+
+	// auto_map output names back to source keys, for metadata
+	var origins map[string]string
+	if families != nil {
+		origins = make(map[string]string)
+	}
+
+	// Auto-map everything that survived the filter; explicit metrics below
+	// take precedence on name collisions
+	if scraper.AutoMap {
+		if err := autoMap(parsed, scraper.NameTransform, result, origins); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	for _, metricMap := range scraper.Metrics {
+		// Glob match: one output metric per matching key
+		if metricMap.Match != "" && strings.ContainsAny(metricMap.Match, "*?[") {
+			for key, value := range parsed {
+				if matched, _ := path.Match(metricMap.Match, key); matched {
+					value = transformValue(value, metricMap)
+					if parsers.InRange(value, metricMap.MinValue, metricMap.MaxValue) {
+						result[metricMap.Name+"_"+key] = value
+					}
+				}
+			}
+			continue
+		}
+
+		// Wildcard path with labels: one labeled series per match
+		if metricMap.Path != "" && metricMap.LabelFrom != "" {
+			labels := strings.Split(metricMap.LabelFrom, ",")
+			for _, match := range utils.GetJSONPathMatches(parsed, metricMap.Path) {
+				value := transformValue(match.Value, metricMap)
+				if !parsers.InRange(value, metricMap.MinValue, metricMap.MaxValue) {
+					continue
+				}
+				kv := make([]string, 0, 2*len(labels))
+				for i, label := range labels {
+					if i < len(match.Captures) {
+						kv = append(kv, strings.TrimSpace(label), match.Captures[i])
+					}
+				}
+				result[utils.Labeled(metricMap.Name, kv...)] = value
+			}
+			continue
+		}
+
 		var value interface{}
 		var found bool
 
@@ -93,31 +241,229 @@ func CollectScraper(scraper config.ScraperConfig) (map[string]interface{}, error
 			continue
 		}
 
-		// Apply calculation if specified
-		if metricMap.Calculate != "" {
-			if numVal, ok := utils.ToFloat64(value); ok {
-				value = utils.Calculate(numVal, metricMap.Calculate)
-			}
+		value = transformValue(value, metricMap)
+		if !parsers.InRange(value, metricMap.MinValue, metricMap.MaxValue) {
+			continue
 		}
 
 		result[metricMap.Name] = value
 	}
 
-	return result, nil
+	// Selected response headers become metrics too
+	for header, name := range scraper.Source.HeaderMetrics {
+		value := headers.Get(header)
+		if value == "" {
+			continue
+		}
+		if num, ok := utils.ToFloat64(value); ok {
+			result[name] = num
+		} else {
+			result[name] = value
+		}
+	}
+
+	var meta map[string]parsers.PromMetadata
+	if families != nil {
+		meta = outputMetadata(scraper, families, origins, result)
+	}
+	return result, meta, nil
+}
+
+// styleNames rewrites every series name in result to the given style,
+// leaving labels untouched
+func styleNames(result map[string]interface{}, style string) map[string]interface{} {
+	styled := make(map[string]interface{}, len(result))
+	for key, value := range result {
+		name, kv := utils.ParseLabeled(key)
+		if converted := utils.ApplyNameStyle(name, style); converted != "" {
+			name = converted
+		}
+		styledKey := utils.Labeled(name, kv...)
+		if _, exists := styled[styledKey]; exists {
+			log.Printf("WARN: name_style %s maps more than one metric to '%s', keeping one", style, styledKey)
+		}
+		styled[styledKey] = value
+	}
+	return styled
 }
 
-func fetchURL(url string) (string, error) {
-	resp, err := getHTTPClient().Get(url)  // Use shared client
+// transformValue applies the per-metric transformations to a matched value
+func transformValue(value interface{}, metricMap config.MetricMap) interface{} {
+	// Decode encoded strings before anything treats them as numbers
+	if metricMap.Decode != "" {
+		decoded, err := utils.Decode(value, metricMap.Decode)
+		if err != nil {
+			log.Printf("WARN: metric %s: %v", metricMap.Name, err)
+		} else {
+			value = decoded
+		}
+	}
+
+	// Reduce an array of samples to a single statistic
+	if metricMap.Summarize != "" {
+		if samples, ok := value.([]interface{}); ok {
+			values := make([]float64, 0, len(samples))
+			for _, sample := range samples {
+				if num, ok := utils.ToFloat64(sample); ok {
+					values = append(values, num)
+				}
+			}
+			if summary, ok := utils.Summarize(values, metricMap.Summarize); ok {
+				value = summary
+			}
+		}
+	}
+
+	// Apply calculation if specified
+	if metricMap.Calculate != "" {
+		if numVal, ok := utils.ToFloat64(value); ok {
+			value = utils.Calculate(numVal, metricMap.Calculate)
+		}
+	}
+
+	// Round if a precision is configured for this metric. An exact integer
+	// from use_number is already rounded and would lose digits as a float.
+	if n, ok := value.(json.Number); ok && !strings.ContainsAny(n.String(), ".eE") {
+		return value
+	}
+	if metricMap.Precision != nil {
+		if utils.IsNumber(value) {
+			numVal, _ := utils.ToFloat64(value)
+			value = utils.Round(numVal, *metricMap.Precision)
+		}
+	}
+
+	return value
+}
+
+// fetchURL returns the response body and headers
+// Delay before the first retry of a failed fetch, doubled for each one after
+const retryBackoff = 200 * time.Millisecond
+
+// fetchURLWithRetries fetches url, retrying up to source.Retries times
+// with backoff while ctx allows
+func fetchURLWithRetries(ctx context.Context, scraper string, source config.SourceConfig, url string) (string, http.Header, error) {
+	delay := retryBackoff
+	for attempt := 0; ; attempt++ {
+		data, headers, err := fetchURL(ctx, source, url)
+		if err == nil || attempt >= source.Retries || ctx.Err() != nil {
+			return data, headers, err
+		}
+		log.Printf("Scraper %s: fetching %s failed: %v, retrying in %v (%d/%d)", scraper, url, err, delay, attempt+1, source.Retries)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+func fetchURL(ctx context.Context, source config.SourceConfig, url string) (string, http.Header, error) {
+	client, err := clientFor(source)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	method := source.Method
+	if method == "" {
+		method = http.MethodGet
+		if source.Body != "" {
+			method = http.MethodPost
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(source.Body))
+	if err != nil {
+		return "", nil, err
+	}
+	if source.Body != "" {
+		contentType := source.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if err := waitOutbound(ctx); err != nil {
+		return "", nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
 	}
 	defer resp.Body.Close()
 
 	// Limit response size
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024)) // 10MB max
+	limit := maxResponseBytes(source)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := checkLimit(body, limit, url)
+	return data, resp.Header, err
+}
+
+func readFile(path string, limit int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
 	if err != nil {
 		return "", err
 	}
 
-	return string(body), nil
-}
\ No newline at end of file
+	return checkLimit(data, limit, path)
+}
+
+func maxResponseBytes(source config.SourceConfig) int64 {
+	if source.MaxResponseBytes > 0 {
+		return source.MaxResponseBytes
+	}
+	return defaultMaxResponseBytes
+}
+
+// checkLimit rejects data read with a limit+1 cap that hit the cap, so an
+// oversized body is never parsed half-way
+func checkLimit(data []byte, limit int64, source string) (string, error) {
+	if int64(len(data)) > limit {
+		log.Printf("WARN: %s exceeded max_response_bytes (%d), rejecting", source, limit)
+		return "", fmt.Errorf("response larger than %d bytes", limit)
+	}
+	return string(data), nil
+}
+
+// autoMap copies every flattened key of parsed into result, renamed by
+// transforms. origins, when non-nil, records each output's source key.
+func autoMap(parsed map[string]interface{}, transforms []config.NameTransform, result map[string]interface{}, origins map[string]string) error {
+	regexes := make([]*regexp.Regexp, len(transforms))
+	for i, t := range transforms {
+		re, err := regexp.Compile(t.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid name_transform regex %q: %v", t.Regex, err)
+		}
+		regexes[i] = re
+	}
+
+	for key, value := range parsers.FlattenObjects(parsed, "") {
+		name := key
+		for i, re := range regexes {
+			name = re.ReplaceAllString(name, transforms[i].Replacement)
+		}
+		if name == "" {
+			continue
+		}
+		result[name] = value
+		if origins != nil {
+			origins[name] = key
+		}
+	}
+
+	return nil
+}
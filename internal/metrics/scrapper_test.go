@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+func TestFallbackURLAfterRetries(t *testing.T) {
+	var primaryHits, fallbackHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		// Drop the connection so the fetch fails
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackHits.Add(1)
+		w.Write([]byte(`{"up": 1}`))
+	}))
+	defer fallback.Close()
+
+	source := config.SourceConfig{Type: "url", URL: primary.URL, FallbackURL: fallback.URL, Retries: 2, Format: "json"}
+	result, _, err := scrapeSource(context.Background(), config.ScraperConfig{Name: "ha", Source: source, AutoMap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := primaryHits.Load(); got != 3 {
+		t.Errorf("primary fetched %d times, want 3", got)
+	}
+	if got := fallbackHits.Load(); got != 1 {
+		t.Errorf("fallback fetched %d times, want 1", got)
+	}
+	if result["up"] != float64(1) {
+		t.Errorf("result = %v, want the fallback's data", result)
+	}
+}
+
+func TestRetriesStopWhenContextDone(t *testing.T) {
+	var hits atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		cancel()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	source := config.SourceConfig{Type: "url", URL: server.URL, Retries: 5}
+	if _, _, err := fetchURLWithRetries(ctx, "ha", source, server.URL); err == nil {
+		t.Fatal("cancelled fetch succeeded")
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("fetched %d times after cancel, want 1", got)
+	}
+}
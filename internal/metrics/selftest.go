@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// A single underlying call made by the system collectors
+type selfTestProbe struct {
+	name    string
+	metrics []string // metrics that depend on this call
+	run     func(ctx context.Context) error
+}
+
+var selfTestProbes = []selfTestProbe{
+	{"cpu.Percent", []string{"cpu_usage_percent", "cpu_usage_per_core"}, func(ctx context.Context) error {
+		_, err := cpu.PercentWithContext(ctx, cpuSampleWindow, true)
+		return err
+	}},
+	{"cpu.Counts", []string{"cpu_count", "cpu_count_physical", "cpu_load_1min_per_core", "cpu_load_5min_per_core", "cpu_load_15min_per_core"}, func(ctx context.Context) error {
+		_, err := cpu.CountsWithContext(ctx, true)
+		return err
+	}},
+	{"load.Avg", []string{"cpu_load_1min", "cpu_load_5min", "cpu_load_15min", "cpu_load_1min_per_core", "cpu_load_5min_per_core", "cpu_load_15min_per_core"}, func(ctx context.Context) error {
+		_, err := load.AvgWithContext(ctx)
+		return err
+	}},
+	{"mem.VirtualMemory", []string{"ram_usage_percent", "available_ram_mb", "total_ram_mb", "ram_cached_mb", "ram_buffers_mb"}, func(ctx context.Context) error {
+		_, err := mem.VirtualMemoryWithContext(ctx)
+		return err
+	}},
+	{"mem.SwapMemory", []string{"swap_usage_percent", "swap_total_mb", "swap_used_mb"}, func(ctx context.Context) error {
+		_, err := mem.SwapMemoryWithContext(ctx)
+		return err
+	}},
+	{"disk.Usage", []string{"disk_usage_percent", "available_disk_gb", "total_disk_gb", "inode_usage_percent", "inodes_total", "inodes_used", "inodes_free"}, func(ctx context.Context) error {
+		_, err := disk.UsageWithContext(ctx, "/")
+		return err
+	}},
+	{"disk.Partitions", []string{"disk_usage_weighted_percent"}, func(ctx context.Context) error {
+		_, err := disk.PartitionsWithContext(ctx, false)
+		return err
+	}},
+	{"disk.IOCounters", []string{"disk_read_bytes", "disk_write_bytes", "disk_read_bytes_per_sec", "disk_write_bytes_per_sec", "disk_read_count", "disk_write_count"}, func(ctx context.Context) error {
+		_, err := disk.IOCountersWithContext(ctx)
+		return err
+	}},
+	{"net.IOCounters", []string{"network_bytes_sent", "network_bytes_recv", "network_bytes_sent_per_sec", "network_bytes_recv_per_sec", "network_packets_sent", "network_packets_recv", "network_errors_in", "network_errors_out"}, func(ctx context.Context) error {
+		_, err := net.IOCountersWithContext(ctx, false)
+		return err
+	}},
+	{"net.Connections", []string{"active_connections", "listening_ports"}, func(ctx context.Context) error {
+		_, err := net.ConnectionsWithContext(ctx, "all")
+		return err
+	}},
+	{"process.Processes", []string{"process_count"}, func(ctx context.Context) error {
+		_, err := process.ProcessesWithContext(ctx)
+		return err
+	}},
+	{"host.Info", []string{"system_uptime_seconds", "boot_time_unix", "os_platform", "os_version", "hostname", "kernel_version"}, func(ctx context.Context) error {
+		_, err := host.InfoWithContext(ctx)
+		return err
+	}},
+	{"file descriptors", []string{"open_file_descriptors", "max_file_descriptors"}, func(ctx context.Context) error {
+		_, _, err := readFileDescriptors()
+		return err
+	}},
+	{"cpu frequency", []string{"cpu_frequency_mhz", "cpu_frequency_per_core_mhz"}, func(ctx context.Context) error {
+		_, err := readCPUFrequencies(ctx)
+		return err
+	}},
+	{"battery", []string{"battery_percent", "battery_charging", "power_plugged"}, func(ctx context.Context) error {
+		_, _, err := readBattery()
+		return err
+	}},
+}
+
+// SelfTest runs every underlying system call once and writes a table of
+// results to w. It returns false if a call needed by a requested metric
+// failed. Init must be called first.
+func SelfTest(w io.Writer) bool {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COLLECTOR\tSTATUS\tDURATION\tREQUESTED\tERROR")
+
+	ok := true
+	for _, probe := range selfTestProbes {
+		ctx, cancel := context.WithTimeout(context.Background(), collectionTimeout)
+		start := time.Now()
+		err := probe.run(ctx)
+		elapsed := time.Since(start)
+		cancel()
+
+		var requested []string
+		for _, metric := range probe.metrics {
+			if requestedMetrics[metric] {
+				requested = append(requested, metric)
+			}
+		}
+
+		status, errText := "ok", ""
+		if err != nil {
+			status, errText = "FAIL", err.Error()
+			if len(requested) > 0 {
+				ok = false
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", probe.name, status, elapsed.Round(time.Millisecond), strings.Join(requested, ","), errText)
+	}
+
+	tw.Flush()
+	return ok
+}
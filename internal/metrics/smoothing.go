@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"log"
+)
+
+// Volatile gauges sampled over a short window at refresh time. Totals,
+// counts and slow-moving values are reported as collected.
+var smoothedMetrics = []string{
+	"cpu_usage_percent", "cpu_usage_per_core",
+	"cpu_frequency_mhz", "cpu_frequency_per_core_mhz",
+	"disk_read_bytes_per_sec", "disk_write_bytes_per_sec",
+	"network_bytes_sent_per_sec", "network_bytes_recv_per_sec",
+}
+
+// Weight given to each new sample, 0 disables smoothing
+var smoothingFactor float64
+
+// Previous smoothed values, guarded by collectionMutex
+var smoothed = make(map[string]interface{})
+
+// initSmoothing validates smoothing_factor and drops history from the
+// previous config
+func initSmoothing(factor float64) {
+	smoothed = make(map[string]interface{})
+	smoothingFactor = 0
+	if factor == 0 {
+		return
+	}
+	if factor < 0 || factor > 1 {
+		log.Printf("WARN: smoothing_factor %v must be between 0 and 1, smoothing disabled", factor)
+		return
+	}
+	smoothingFactor = factor
+}
+
+// applySmoothing replaces volatile gauges in metrics with an exponential
+// moving average across collections. The first collection, and any metric
+// whose shape changed (e.g. a core went offline), starts from the raw value.
+func applySmoothing(metrics map[string]interface{}) {
+	if smoothingFactor == 0 {
+		return
+	}
+
+	for _, name := range smoothedMetrics {
+		switch value := metrics[name].(type) {
+		case float64:
+			if prev, ok := smoothed[name].(float64); ok {
+				value = round(ema(prev, value))
+			}
+			metrics[name] = value
+			smoothed[name] = value
+		case []float64:
+			prev, ok := smoothed[name].([]float64)
+			if ok && len(prev) == len(value) {
+				averaged := make([]float64, len(value))
+				for i := range value {
+					averaged[i] = round(ema(prev[i], value[i]))
+				}
+				value = averaged
+			}
+			metrics[name] = value
+			smoothed[name] = value
+		default:
+			// Not collected this time; keep history so a skipped
+			// collection doesn't reset the average
+		}
+	}
+}
+
+func ema(prev, sample float64) float64 {
+	return smoothingFactor*sample + (1-smoothingFactor)*prev
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// snappyDecode is a strict decoder for the snappy block format, written
+// from the format description. It rejects bad offsets, truncation and
+// length mismatches.
+func snappyDecode(src []byte) ([]byte, error) {
+	n, read := binary.Uvarint(src)
+	if read <= 0 {
+		return nil, errors.New("bad length")
+	}
+	src = src[read:]
+	dst := make([]byte, 0, n)
+
+	for len(src) > 0 {
+		tag := src[0]
+		src = src[1:]
+		var length, offset int
+		switch tag & 0x03 {
+		case 0x00: // literal
+			length = int(tag >> 2)
+			if length >= 60 {
+				size := length - 59
+				if len(src) < size {
+					return nil, errors.New("truncated literal length")
+				}
+				length = 0
+				for i := size - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[size:]
+			}
+			length++
+			if len(src) < length {
+				return nil, errors.New("truncated literal")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 0x01:
+			if len(src) < 1 {
+				return nil, errors.New("truncated copy")
+			}
+			length = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[0])
+			src = src[1:]
+		case 0x02:
+			if len(src) < 2 {
+				return nil, errors.New("truncated copy")
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src))
+			src = src[2:]
+		case 0x03:
+			if len(src) < 4 {
+				return nil, errors.New("truncated copy")
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src))
+			src = src[4:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, errors.New("bad copy offset")
+		}
+		// Copies may overlap their own output
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+
+	if uint64(len(dst)) != n {
+		return nil, errors.New("decoded length mismatch")
+	}
+	return dst, nil
+}
+
+func TestSnappyEncodeGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []byte
+		want []byte
+	}{
+		{"empty", nil, []byte{0x00}},
+		{"literal", []byte("abc"), []byte{0x03, 0x08, 'a', 'b', 'c'}},
+		// One literal byte, then a 2-byte-offset copy of 19 at offset 1
+		{"run", bytes.Repeat([]byte("a"), 20), []byte{0x14, 0x00, 'a', 0x4a, 0x01, 0x00}},
+		// Copies longer than 64 are split
+		{"long run", bytes.Repeat([]byte("a"), 70), []byte{0x46, 0x00, 'a', 0xfe, 0x01, 0x00, 0x12, 0x01, 0x00}},
+	}
+	for _, tt := range tests {
+		if got := snappyEncode(tt.src); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: snappyEncode = % x, want % x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	random := make([]byte, 3*snappyMaxBlock+17)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	inputs := map[string][]byte{
+		"empty":                        {},
+		"short":                        []byte("abc"),
+		"60 literal":                   []byte(strings.Repeat("0123456789", 6)),
+		"incompressible across blocks": random,
+		"repetitive across blocks":     []byte(strings.Repeat(`cpu_usage_percent{instance="host-01",job="probestyx"} `, 5000)),
+		"write request": encodeWriteRequest([]promSeries{
+			{labels: []promLabel{{"__name__", "cpu_usage_percent"}, {"instance", "host-01"}}, value: 12.5, timestamp: 1700000000000},
+			{labels: []promLabel{{"__name__", "disk_usage_percent"}, {"instance", "host-01"}, {"mount", "/"}}, value: 40, timestamp: 1700000000000},
+		}),
+	}
+
+	for name, src := range inputs {
+		encoded := snappyEncode(src)
+		decoded, err := snappyDecode(encoded)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(decoded, src) {
+			t.Errorf("%s: round trip changed the data", name)
+		}
+		if strings.HasPrefix(name, "repetitive") && len(encoded) > len(src)/10 {
+			t.Errorf("%s: compressed %d bytes to %d", name, len(src), len(encoded))
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+const (
+	snmpDefaultPort = "161"
+	snmpTimeout     = 2 * time.Second
+	snmpRetries     = 1
+	snmpMaxPacket   = 65535
+)
+
+// BER/SNMP tags
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+
+	snmpIPAddress  = 0x40
+	snmpCounter32  = 0x41
+	snmpGauge32    = 0x42
+	snmpTimeTicks  = 0x43
+	snmpOpaque     = 0x44
+	snmpCounter64  = 0x46
+	snmpNoSuchObj  = 0x80
+	snmpNoSuchInst = 0x81
+	snmpEndOfView  = 0x82
+
+	snmpGetRequest  = 0xa0
+	snmpGetResponse = 0xa2
+)
+
+// getSNMP issues a single GET for every configured OID and returns the
+// values keyed by their mapped metric name. Missing OIDs are skipped.
+func getSNMP(source config.SourceConfig) (map[string]interface{}, error) {
+	if source.SNMPTarget == "" {
+		return nil, fmt.Errorf("snmp source requires snmp_target")
+	}
+	if len(source.OIDs) == 0 {
+		return nil, fmt.Errorf("snmp source requires at least one oid")
+	}
+
+	version := 1 // SNMPv2c
+	switch source.Version {
+	case "", "2c", "2":
+	case "1":
+		version = 0
+	default:
+		return nil, fmt.Errorf("unsupported snmp version: %s (use 1 or 2c)", source.Version)
+	}
+
+	community := source.Community
+	if community == "" {
+		community = "public"
+	}
+
+	target := source.SNMPTarget
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, snmpDefaultPort)
+	}
+
+	oids := make([]string, 0, len(source.OIDs))
+	for oid := range source.OIDs {
+		oids = append(oids, strings.TrimPrefix(oid, "."))
+	}
+
+	requestID := rand.Int31()
+	packet, err := encodeSNMPGet(version, community, requestID, oids)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := snmpExchange(target, packet, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for oid, name := range source.OIDs {
+		if value, ok := values[strings.TrimPrefix(oid, ".")]; ok {
+			result[name] = value
+		}
+	}
+	return result, nil
+}
+
+// A response whose request-id belongs to another request, e.g. a late
+// reply to an earlier one
+var errSNMPStaleResponse = errors.New("snmp: response does not match request")
+
+// snmpExchange sends a request over UDP and decodes the reply, retrying
+// once on timeout
+func snmpExchange(target string, packet []byte, requestID int32) (map[string]interface{}, error) {
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, snmpMaxPacket)
+	for attempt := 0; ; attempt++ {
+		if _, err := conn.Write(packet); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(snmpTimeout))
+		values, err := readSNMPResponse(conn, buf, requestID)
+		if err == nil {
+			return values, nil
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) {
+			return nil, err
+		}
+		if attempt >= snmpRetries || !netErr.Timeout() {
+			return nil, fmt.Errorf("snmp %s: %w", target, err)
+		}
+	}
+}
+
+// readSNMPResponse reads until the reply to requestID arrives, discarding
+// stale replies, or the read deadline passes
+func readSNMPResponse(conn net.Conn, buf []byte, requestID int32) (map[string]interface{}, error) {
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		values, err := decodeSNMPResponse(buf[:n], requestID)
+		if errors.Is(err, errSNMPStaleResponse) {
+			continue
+		}
+		return values, err
+	}
+}
+
+func encodeSNMPGet(version int, community string, requestID int32, oids []string) ([]byte, error) {
+	var varbinds []byte
+	for _, oid := range oids {
+		encoded, err := encodeOID(oid)
+		if err != nil {
+			return nil, err
+		}
+		varbinds = append(varbinds, berTLV(berSequence, append(encoded, berNull, 0x00))...)
+	}
+
+	pdu := berInt(int64(requestID))
+	pdu = append(pdu, berInt(0)...) // error-status
+	pdu = append(pdu, berInt(0)...) // error-index
+	pdu = append(pdu, berTLV(berSequence, varbinds)...)
+
+	msg := berInt(int64(version))
+	msg = append(msg, berTLV(berOctetString, []byte(community))...)
+	msg = append(msg, berTLV(snmpGetRequest, pdu)...)
+	return berTLV(berSequence, msg), nil
+}
+
+func berTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	n := len(value)
+	if n < 0x80 {
+		out = append(out, byte(n))
+	} else {
+		var length []byte
+		for ; n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		out = append(out, 0x80|byte(len(length)))
+		out = append(out, length...)
+	}
+	return append(out, value...)
+}
+
+func berInt(v int64) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		// Stop once the remaining bits are pure sign extension
+		if (v == 0 && b[0]&0x80 == 0) || (v == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return berTLV(berInteger, b)
+}
+
+func encodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid oid: %s", oid)
+	}
+	arcs := make([]uint64, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid oid: %s", oid)
+		}
+		arcs[i] = arc
+	}
+
+	body := appendBase128(nil, arcs[0]*40+arcs[1])
+	for _, arc := range arcs[2:] {
+		body = appendBase128(body, arc)
+	}
+	return berTLV(berOID, body), nil
+}
+
+func appendBase128(b []byte, v uint64) []byte {
+	var tmp []byte
+	tmp = append(tmp, byte(v&0x7f))
+	for v >>= 7; v > 0; v >>= 7 {
+		tmp = append([]byte{byte(v&0x7f) | 0x80}, tmp...)
+	}
+	return append(b, tmp...)
+}
+
+// berReader walks a BER-encoded buffer one TLV at a time
+type berReader struct {
+	buf []byte
+}
+
+func (r *berReader) next() (byte, []byte, error) {
+	if len(r.buf) < 2 {
+		return 0, nil, fmt.Errorf("snmp: truncated packet")
+	}
+	tag := r.buf[0]
+	length := int(r.buf[1])
+	offset := 2
+	if length&0x80 != 0 {
+		size := length & 0x7f
+		if size == 0 || size > 4 || len(r.buf) < offset+size {
+			return 0, nil, fmt.Errorf("snmp: bad length encoding")
+		}
+		length = 0
+		for _, b := range r.buf[offset : offset+size] {
+			length = length<<8 | int(b)
+		}
+		offset += size
+	}
+	if length < 0 || len(r.buf)-offset < length {
+		return 0, nil, fmt.Errorf("snmp: truncated packet")
+	}
+	value := r.buf[offset : offset+length]
+	r.buf = r.buf[offset+length:]
+	return tag, value, nil
+}
+
+func (r *berReader) expect(tag byte) ([]byte, error) {
+	got, value, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if got != tag {
+		return nil, fmt.Errorf("snmp: expected tag 0x%02x, got 0x%02x", tag, got)
+	}
+	return value, nil
+}
+
+func decodeSNMPResponse(packet []byte, requestID int32) (map[string]interface{}, error) {
+	msg, err := (&berReader{packet}).expect(berSequence)
+	if err != nil {
+		return nil, err
+	}
+	r := &berReader{msg}
+	if _, err := r.expect(berInteger); err != nil { // version
+		return nil, err
+	}
+	if _, err := r.expect(berOctetString); err != nil { // community
+		return nil, err
+	}
+	pdu, err := r.expect(snmpGetResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &berReader{pdu}
+	fields := make([]int64, 3) // request-id, error-status, error-index
+	for i := range fields {
+		raw, err := p.expect(berInteger)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = decodeInt(raw)
+	}
+	if fields[0] != int64(requestID) {
+		return nil, fmt.Errorf("%w: got id %d, sent %d", errSNMPStaleResponse, fields[0], requestID)
+	}
+	if fields[1] != 0 {
+		return nil, fmt.Errorf("snmp: agent returned error-status %d at index %d", fields[1], fields[2])
+	}
+
+	list, err := p.expect(berSequence)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	vbs := &berReader{list}
+	for len(vbs.buf) > 0 {
+		vb, err := vbs.expect(berSequence)
+		if err != nil {
+			return nil, err
+		}
+		v := &berReader{vb}
+		rawOID, err := v.expect(berOID)
+		if err != nil {
+			return nil, err
+		}
+		tag, raw, err := v.next()
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := decodeSNMPValue(tag, raw); ok {
+			values[decodeOID(rawOID)] = value
+		}
+	}
+	return values, nil
+}
+
+// decodeSNMPValue converts a varbind value to a float64 (numeric types) or
+// string. Exceptions such as noSuchObject report false.
+func decodeSNMPValue(tag byte, raw []byte) (interface{}, bool) {
+	switch tag {
+	case berInteger:
+		return float64(decodeInt(raw)), true
+	case snmpCounter32, snmpGauge32, snmpTimeTicks, snmpCounter64:
+		return float64(decodeUint(raw)), true
+	case berOctetString, snmpOpaque:
+		return string(bytes.TrimRight(raw, "\x00")), true
+	case snmpIPAddress:
+		return net.IP(raw).String(), true
+	case berOID:
+		return decodeOID(raw), true
+	case berNull, snmpNoSuchObj, snmpNoSuchInst, snmpEndOfView:
+		return nil, false
+	}
+	return nil, false
+}
+
+func decodeInt(raw []byte) int64 {
+	var v int64
+	for i, b := range raw {
+		if i == 0 && b&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+func decodeUint(raw []byte) uint64 {
+	var v uint64
+	for _, b := range raw {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func decodeOID(raw []byte) string {
+	var arcs []string
+	var v uint64
+	for _, b := range raw {
+		v = v<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			continue
+		}
+		if len(arcs) == 0 {
+			first := v / 40
+			if first > 2 {
+				first = 2
+			}
+			arcs = append(arcs, strconv.FormatUint(first, 10), strconv.FormatUint(v-first*40, 10))
+		} else {
+			arcs = append(arcs, strconv.FormatUint(v, 10))
+		}
+		v = 0
+	}
+	return strings.Join(arcs, ".")
+}
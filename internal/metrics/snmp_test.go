@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+type testVarbind struct {
+	oid   string
+	tag   byte
+	value []byte
+}
+
+// encodeSNMPResponse builds a GetResponse the way an agent would
+func encodeSNMPResponse(t *testing.T, requestID int32, errorStatus int64, varbinds []testVarbind) []byte {
+	t.Helper()
+	var list []byte
+	for _, vb := range varbinds {
+		oid, err := encodeOID(vb.oid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, berTLV(berSequence, append(oid, berTLV(vb.tag, vb.value)...))...)
+	}
+
+	pdu := berInt(int64(requestID))
+	pdu = append(pdu, berInt(errorStatus)...)
+	pdu = append(pdu, berInt(0)...)
+	pdu = append(pdu, berTLV(berSequence, list)...)
+
+	msg := berInt(1)
+	msg = append(msg, berTLV(berOctetString, []byte("public"))...)
+	msg = append(msg, berTLV(snmpGetResponse, pdu)...)
+	return berTLV(berSequence, msg)
+}
+
+func TestEncodeSNMPGet(t *testing.T) {
+	// GET sysUpTime.0, as sent by `snmpget -v2c -c public`
+	want := []byte{
+		0x30, 0x26,
+		0x02, 0x01, 0x01,
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x19,
+		0x02, 0x01, 0x01,
+		0x02, 0x01, 0x00,
+		0x02, 0x01, 0x00,
+		0x30, 0x0e, 0x30, 0x0c,
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00,
+		0x05, 0x00,
+	}
+	got, err := encodeSNMPGet(1, "public", 1, []string{"1.3.6.1.2.1.1.3.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeSNMPGet = % x, want % x", got, want)
+	}
+}
+
+func TestBERIntRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64} {
+		r := &berReader{berInt(v)}
+		raw, err := r.expect(berInteger)
+		if err != nil {
+			t.Fatalf("%d: %v", v, err)
+		}
+		if got := decodeInt(raw); got != v {
+			t.Errorf("berInt(%d) decodes to %d", v, got)
+		}
+	}
+}
+
+func TestOIDRoundTrip(t *testing.T) {
+	for _, oid := range []string{"1.3.6.1.2.1.1.3.0", "1.3.6.1.4.1.2021.10.1.3.1", "2.999.3", "1.3.6.1.4.1.4294967295.18446744073709551615"} {
+		encoded, err := encodeOID(oid)
+		if err != nil {
+			t.Fatalf("%s: %v", oid, err)
+		}
+		raw, err := (&berReader{encoded}).expect(berOID)
+		if err != nil {
+			t.Fatalf("%s: %v", oid, err)
+		}
+		if got := decodeOID(raw); got != oid {
+			t.Errorf("%s decodes to %s", oid, got)
+		}
+	}
+
+	for _, oid := range []string{"1", "", "1.3.x", "1.3.-1"} {
+		if _, err := encodeOID(oid); err == nil {
+			t.Errorf("encodeOID(%q) accepted an invalid oid", oid)
+		}
+	}
+}
+
+func TestDecodeSNMPResponse(t *testing.T) {
+	long := strings.Repeat("x", 300) // needs a long-form length
+	packet := encodeSNMPResponse(t, 42, 0, []testVarbind{
+		{"1.3.6.1.2.1.1.3.0", snmpTimeTicks, []byte{0x01, 0x00, 0x00}},
+		{"1.3.6.1.2.1.1.5.0", berOctetString, []byte("router\x00")},
+		{"1.3.6.1.2.1.1.6.0", berOctetString, []byte(long)},
+		{"1.3.6.1.2.1.4.20.1.1.0", snmpIPAddress, []byte{10, 0, 0, 1}},
+		{"1.3.6.1.2.1.1.2.0", berOID, []byte{0x2b, 0x06, 0x01}},
+		{"1.3.6.1.4.1.1.1", berInteger, []byte{0xff, 0x38}},
+		{"1.3.6.1.2.1.31.1.1.1.6.1", snmpCounter64, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"1.3.6.1.2.1.1.99.0", snmpNoSuchObj, nil},
+		{"1.3.6.1.2.1.1.98.0", snmpNoSuchInst, nil},
+	})
+
+	got, err := decodeSNMPResponse(packet, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"1.3.6.1.2.1.1.3.0":        float64(65536),
+		"1.3.6.1.2.1.1.5.0":        "router",
+		"1.3.6.1.2.1.1.6.0":        long,
+		"1.3.6.1.2.1.4.20.1.1.0":   "10.0.0.1",
+		"1.3.6.1.2.1.1.2.0":        "1.3.6.1",
+		"1.3.6.1.4.1.1.1":          float64(-200),
+		"1.3.6.1.2.1.31.1.1.1.6.1": float64(math.MaxUint64),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeSNMPResponse = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeSNMPResponseErrors(t *testing.T) {
+	valid := encodeSNMPResponse(t, 7, 0, []testVarbind{{"1.3.6.1.2.1.1.5.0", berOctetString, []byte("router")}})
+
+	// Every truncation must fail cleanly
+	for i := 0; i < len(valid); i++ {
+		if _, err := decodeSNMPResponse(valid[:i], 7); err == nil {
+			t.Errorf("truncated to %d bytes: no error", i)
+		}
+	}
+
+	if _, err := decodeSNMPResponse(valid, 8); !errors.Is(err, errSNMPStaleResponse) {
+		t.Errorf("mismatched request id: got %v, want errSNMPStaleResponse", err)
+	}
+	if _, err := decodeSNMPResponse(encodeSNMPResponse(t, 7, 2, nil), 7); err == nil {
+		t.Error("error-status 2 was not reported")
+	}
+
+	request, err := encodeSNMPGet(1, "public", 7, []string{"1.3.6.1.2.1.1.5.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	malformed := map[string][]byte{
+		"empty":              {},
+		"not a sequence":     append([]byte{0x31}, valid[1:]...),
+		"get request pdu":    request,
+		"indefinite length":  {0x30, 0x80, 0x00, 0x00},
+		"5-byte length":      {0x30, 0x85, 0x01, 0x00, 0x00, 0x00, 0x00},
+		"length past end":    {0x30, 0x84, 0x7f, 0xff, 0xff, 0xff, 0x00},
+		"negative length":    {0x30, 0x84, 0xff, 0xff, 0xff, 0xff, 0x00},
+		"length short form":  {0x30, 0x7f, 0x02, 0x01, 0x01},
+		"varbind not a seq":  encodeSNMPResponseRaw(7, berTLV(berInteger, []byte{1})),
+		"varbind without id": encodeSNMPResponseRaw(7, berTLV(berSequence, berTLV(berNull, nil))),
+	}
+	for name, packet := range malformed {
+		if _, err := decodeSNMPResponse(packet, 7); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// encodeSNMPResponseRaw wraps an arbitrary varbind list in a GetResponse
+func encodeSNMPResponseRaw(requestID int32, list []byte) []byte {
+	pdu := berInt(int64(requestID))
+	pdu = append(pdu, berInt(0)...)
+	pdu = append(pdu, berInt(0)...)
+	pdu = append(pdu, berTLV(berSequence, list)...)
+
+	msg := berInt(1)
+	msg = append(msg, berTLV(berOctetString, []byte("public"))...)
+	msg = append(msg, berTLV(snmpGetResponse, pdu)...)
+	return berTLV(berSequence, msg)
+}
+
+func TestSNMPExchangeSkipsStaleReplies(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	go func() {
+		buf := make([]byte, snmpMaxPacket)
+		n, from, err := agent.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		// Pull the request-id out of the GET
+		msg, _ := (&berReader{buf[:n]}).expect(berSequence)
+		r := &berReader{msg}
+		r.next()
+		r.next()
+		pdu, _ := r.expect(snmpGetRequest)
+		rawID, _ := (&berReader{pdu}).expect(berInteger)
+		id := int32(decodeInt(rawID))
+
+		// A late reply to some earlier request, then the real answer
+		agent.WriteTo(encodeSNMPResponse(t, id+1, 0, []testVarbind{{"1.3.6.1.2.1.1.5.0", berOctetString, []byte("stale")}}), from)
+		agent.WriteTo(encodeSNMPResponse(t, id, 0, []testVarbind{{"1.3.6.1.2.1.1.5.0", berOctetString, []byte("router")}}), from)
+	}()
+
+	got, err := getSNMP(config.SourceConfig{
+		SNMPTarget: agent.LocalAddr().String(),
+		OIDs:       map[string]string{".1.3.6.1.2.1.1.5.0": "sys_name"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["sys_name"] != "router" {
+		t.Errorf("sys_name = %v, want router", got["sys_name"])
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// expandSources returns one single-source scraper per entry in sources,
+// each carrying that entry's source and metric maps along with the
+// scraper's own filter and auto-map settings. A scraper without sources
+// is returned as is.
+func expandSources(scraper config.ScraperConfig) []config.ScraperConfig {
+	if len(scraper.Sources) == 0 {
+		return []config.ScraperConfig{scraper}
+	}
+
+	expanded := make([]config.ScraperConfig, len(scraper.Sources))
+	for i, sub := range scraper.Sources {
+		single := scraper
+		single.Source = sub.SourceConfig
+		single.Metrics = sub.Metrics
+		single.Sources = nil
+		expanded[i] = single
+	}
+	return expanded
+}
+
+// sourceName identifies a source in error messages
+func sourceName(source config.SourceConfig) string {
+	switch {
+	case source.URL != "":
+		return source.URL
+	case source.Path != "":
+		return source.Path
+	case source.SNMPTarget != "":
+		return source.SNMPTarget
+	case source.SSHHost != "":
+		return source.SSHHost
+	case source.DSN != "":
+		return source.Driver
+	}
+	return source.Type
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// fakeResult is what the fake driver returns for one query
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// fakeSQLDriver answers queries from a fixed table, so the database/sql
+// path can be tested without a real database
+type fakeSQLDriver struct {
+	results map[string]fakeResult
+}
+
+func (d *fakeSQLDriver) Open(string) (driver.Conn, error) { return &fakeSQLConn{d}, nil }
+
+type fakeSQLConn struct{ d *fakeSQLDriver }
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	result, ok := c.d.results[query]
+	if !ok {
+		return nil, errors.New("no such table")
+	}
+	return &fakeSQLStmt{result}, nil
+}
+func (c *fakeSQLConn) Close() error              { return nil }
+func (c *fakeSQLConn) Begin() (driver.Tx, error) { return nil, errors.New("read only") }
+
+type fakeSQLStmt struct{ result fakeResult }
+
+func (s *fakeSQLStmt) Close() error  { return nil }
+func (s *fakeSQLStmt) NumInput() int { return 0 }
+func (s *fakeSQLStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("read only")
+}
+func (s *fakeSQLStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeSQLRows{result: s.result}, nil
+}
+
+type fakeSQLRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeSQLRows) Columns() []string { return r.result.columns }
+func (r *fakeSQLRows) Close() error      { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+func init() {
+	sql.Register("probestyx_fake", &fakeSQLDriver{results: map[string]fakeResult{
+		"SELECT count(*) FROM jobs": {
+			columns: []string{"count"},
+			rows:    [][]driver.Value{{int64(42)}},
+		},
+		"SELECT * FROM stats": {
+			columns: []string{"total", "ratio", "label", "empty", "missing", "healthy"},
+			rows: [][]driver.Value{
+				{int64(7), 0.25, []byte("primary"), "", nil, true},
+				{int64(8), 0.5, []byte("second row"), "x", nil, false},
+			},
+		},
+		"SELECT * FROM nothing": {columns: []string{"a"}},
+	}})
+	sqlDriverNames["fake"] = []string{"probestyx_fake"}
+}
+
+func TestQuerySQLThroughDatabaseSQL(t *testing.T) {
+	source := config.SourceConfig{
+		Type:   "sql",
+		Driver: "fake",
+		DSN:    "fake.db",
+		Queries: []config.SQLQuery{
+			{Query: "SELECT count(*) FROM jobs", Name: "pending"},
+			{Query: "SELECT * FROM stats"},
+			{Query: "SELECT * FROM nothing", Name: "skipped"},
+		},
+	}
+	defer closeUnusedSQLDBs(nil)
+
+	got, err := querySQL(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"pending": float64(42),
+		"total":   float64(7),
+		"ratio":   0.25,
+		"label":   "primary",
+		"empty":   "",
+		"healthy": float64(1),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("querySQL = %v, want %v", got, want)
+	}
+
+	// The pool is kept for the next scrape and counted as state
+	if StateSize() < 1 {
+		t.Error("connection pool not reused across scrapes")
+	}
+
+	source.Queries = []config.SQLQuery{{Query: "SELECT * FROM missing"}}
+	if _, err := querySQL(context.Background(), source); err == nil {
+		t.Error("failing query did not fail the scrape")
+	}
+}
+
+func TestSQLDriverDSN(t *testing.T) {
+	tests := []struct {
+		driver, dsn, want string
+	}{
+		{"sqlite", "/var/lib/app/app.db", "file:/var/lib/app/app.db?mode=ro"},
+		{"sqlite", "/data/a b?.db", "file:/data/a%20b%3F.db?mode=ro"},
+		{"sqlite3", "file:app.db?cache=shared", "file:app.db?cache=shared"},
+		{"mysql", "mysql://app:s3cret@db:3307/shop", "app:s3cret@tcp(db:3307)/shop"},
+		{"mysql", "mysql://app:p%40ss@db/shop", "app:p@ss@tcp(db:3306)/shop"},
+		{"mysql", "mysql://app@db/shop?tls=true", "app@tcp(db:3306)/shop?tls=true"},
+		{"pgx", "postgres://app:pw@db/app", "postgres://app:pw@db/app"},
+	}
+	for _, tt := range tests {
+		got, err := sqlDriverDSN(tt.driver, tt.dsn)
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.driver, tt.dsn, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sqlDriverDSN(%s, %s) = %s, want %s", tt.driver, tt.dsn, got, tt.want)
+		}
+	}
+}
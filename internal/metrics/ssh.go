@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// Upper bound on a remote command, including connection setup
+const sshTimeout = 30 * time.Second
+
+// readSSH runs the source's command (or cats its path) on a remote host
+// through the system ssh client. On Unix, connections are multiplexed over
+// a persistent control socket so repeated scrapes reuse one session.
+func readSSH(ctx context.Context, source config.SourceConfig, limit int64) (string, error) {
+	if source.SSHHost == "" {
+		return "", fmt.Errorf("ssh source requires ssh_host")
+	}
+
+	remote := source.Command
+	if remote == "" {
+		if source.Path == "" {
+			return "", fmt.Errorf("ssh source requires command or path")
+		}
+		remote = "cat " + shellQuote(source.Path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sshTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ssh", append(sshArgs(source), "--", remote)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	data, readErr := io.ReadAll(io.LimitReader(stdout, limit+1))
+	if int64(len(data)) > limit {
+		// Stop the remote command instead of draining the rest
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	if int64(len(data)) > limit {
+		return checkLimit(data, limit, source.SSHHost)
+	}
+	if readErr != nil {
+		return "", readErr
+	}
+	if waitErr != nil {
+		return "", fmt.Errorf("ssh %s: %v: %s", source.SSHHost, waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return string(data), nil
+}
+
+// Private directory holding the SSH control sockets, created on first use.
+// Anyone able to create a socket at a predictable shared path could have
+// our ssh run its commands over their connection, so it is 0700 and
+// randomly named.
+var (
+	sshControlDir   string
+	sshControlDirMu sync.Mutex
+)
+
+// sshControlPath returns the control socket pattern, or "" when the
+// directory can't be created and connections aren't multiplexed
+func sshControlPath() string {
+	sshControlDirMu.Lock()
+	defer sshControlDirMu.Unlock()
+	if sshControlDir == "" {
+		dir, err := os.MkdirTemp("", "probestyx-ssh-")
+		if err != nil {
+			log.Printf("WARN: ssh: cannot create control socket directory, connections won't be reused: %v", err)
+			return ""
+		}
+		sshControlDir = dir
+	}
+	return filepath.Join(sshControlDir, "%C")
+}
+
+// removeSSHControlDir deletes the control socket directory; multiplexed
+// masters exit once their socket is gone
+func removeSSHControlDir() {
+	sshControlDirMu.Lock()
+	defer sshControlDirMu.Unlock()
+	if sshControlDir != "" {
+		os.RemoveAll(sshControlDir)
+		sshControlDir = ""
+	}
+}
+
+func sshArgs(source config.SourceConfig) []string {
+	hostKeyCheck := "yes"
+	if source.AcceptNewHostKey {
+		hostKeyCheck = "accept-new"
+	}
+
+	args := []string{
+		"-o", "BatchMode=yes", // never prompt for passwords or passphrases
+		"-o", "ConnectTimeout=10",
+		"-o", "StrictHostKeyChecking=" + hostKeyCheck,
+	}
+	if runtime.GOOS != "windows" {
+		if controlPath := sshControlPath(); controlPath != "" {
+			args = append(args,
+				"-o", "ControlMaster=auto",
+				"-o", "ControlPath="+controlPath,
+				"-o", "ControlPersist=5m",
+			)
+		}
+	}
+	if source.SSHPort > 0 {
+		args = append(args, "-p", strconv.Itoa(source.SSHPort))
+	}
+	if source.SSHKey != "" {
+		args = append(args, "-i", source.SSHKey, "-o", "IdentitiesOnly=yes")
+	}
+	if source.SSHUser != "" {
+		args = append(args, "-l", source.SSHUser)
+	}
+	return append(args, source.SSHHost)
+}
+
+// shellQuote single-quotes s for the remote shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
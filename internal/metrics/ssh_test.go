@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+func TestSSHArgsHostKeyChecking(t *testing.T) {
+	defer removeSSHControlDir()
+
+	if args := sshArgs(config.SourceConfig{SSHHost: "db1"}); !slices.Contains(args, "StrictHostKeyChecking=yes") {
+		t.Errorf("unknown host keys are trusted by default: %v", args)
+	}
+	if args := sshArgs(config.SourceConfig{SSHHost: "db1", AcceptNewHostKey: true}); !slices.Contains(args, "StrictHostKeyChecking=accept-new") {
+		t.Errorf("accept_new_host_key not applied: %v", args)
+	}
+}
+
+func TestSSHControlPathIsPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no control sockets on Windows")
+	}
+	defer removeSSHControlDir()
+
+	var controlPath string
+	for _, arg := range sshArgs(config.SourceConfig{SSHHost: "db1"}) {
+		if strings.HasPrefix(arg, "ControlPath=") {
+			controlPath = strings.TrimPrefix(arg, "ControlPath=")
+		}
+	}
+	if controlPath == "" {
+		t.Fatal("no ControlPath set")
+	}
+
+	dir := filepath.Dir(controlPath)
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("control socket directory has mode %o, want 700", perm)
+	}
+
+	removeSSHControlDir()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("control socket directory survived shutdown: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// Default lifetime of per-scraper state that stops being updated
+const defaultStateTTL = time.Hour
+
+// Per-scraper health. A scraper is only marked down after failure_threshold
+// consecutive failures and up again after recovery_threshold consecutive
+// successes, so one transient error doesn't flip it.
+type scraperState struct {
+	down                 bool
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	lastError            string
+	lastSeen             time.Time
+
+	// Status last reported to on_failure_webhook
+	notifiedDown bool
+	lastNotified time.Time
+}
+
+var (
+	scraperStates   = make(map[string]*scraperState)
+	scraperStatesMu sync.Mutex
+)
+
+// Per-scraper state not updated for this long is dropped; set at Init
+var stateTTL time.Duration
+
+// Names of the configured scrapers, whose state is never evicted; set at
+// Init under scraperStatesMu
+var configuredScrapers map[string]bool
+
+// recordScrapeResult updates the scraper's consecutive counts and down
+// status, then lets its webhook report any transition.
+func recordScrapeResult(scraper config.ScraperConfig, scrapeErr error) {
+	now := time.Now()
+
+	scraperStatesMu.Lock()
+	defer scraperStatesMu.Unlock()
+
+	state, ok := scraperStates[scraper.Name]
+	if !ok {
+		state = &scraperState{}
+		scraperStates[scraper.Name] = state
+	}
+	state.lastSeen = now
+	evictExpiredStates(now)
+
+	if scrapeErr != nil {
+		state.consecutiveFailures++
+		state.consecutiveSuccesses = 0
+		state.lastError = scrapeErr.Error()
+		if state.consecutiveFailures >= max(scraper.FailureThreshold, 1) {
+			state.down = true
+		}
+	} else {
+		state.consecutiveSuccesses++
+		state.consecutiveFailures = 0
+		if state.consecutiveSuccesses >= max(scraper.RecoveryThreshold, 1) {
+			state.down = false
+		}
+	}
+
+	notifyWebhook(scraper.Name, scraper.OnFailureWebhook, state, now)
+}
+
+// ScraperUp reports whether a scraper is currently considered up, and false
+// for ok when it hasn't been scraped yet
+func ScraperUp(name string) (up bool, ok bool) {
+	scraperStatesMu.Lock()
+	defer scraperStatesMu.Unlock()
+
+	state, ok := scraperStates[name]
+	if !ok {
+		return false, false
+	}
+	return !state.down, true
+}
+
+// evictExpiredStates drops states of scrapers no longer configured that
+// haven't been updated within stateTTL, such as one recorded by a scrape
+// that finished after a reload removed its scraper. Configured scrapers
+// keep their health however rarely they are scraped. Callers must hold
+// scraperStatesMu.
+func evictExpiredStates(now time.Time) {
+	if stateTTL <= 0 {
+		return
+	}
+	for name, state := range scraperStates {
+		if !configuredScrapers[name] && now.Sub(state.lastSeen) > stateTTL {
+			delete(scraperStates, name)
+		}
+	}
+}
+
+// pruneScraperState drops health state, captured metadata, TLS clients and
+// SQL pools that no configured scraper uses any more, so a hot-reload that
+// removes or changes scrapers doesn't leave their state behind.
+func pruneScraperState(c *config.Config) {
+	stateTTL = defaultStateTTL
+	if c.Server.ScraperStateTTLSeconds > 0 {
+		stateTTL = time.Duration(c.Server.ScraperStateTTLSeconds) * time.Second
+	} else if c.Server.ScraperStateTTLSeconds < 0 {
+		stateTTL = 0
+	}
+
+	names := make(map[string]bool, len(c.Scrapers))
+	clientKeys := make(map[string]bool)
+	dbKeys := make(map[string]bool)
+	for _, scraper := range c.Scrapers {
+		names[scraper.Name] = true
+		for _, sub := range expandSources(scraper) {
+			if hasTLSConfig(sub.Source) {
+				clientKeys[tlsClientKey(sub.Source)] = true
+			}
+			if sub.Source.Type == "sql" {
+				dbKeys[sqlDBKey(sub.Source.Driver, sub.Source.DSN)] = true
+			}
+		}
+	}
+
+	scraperStatesMu.Lock()
+	configuredScrapers = names
+	for name := range scraperStates {
+		if !names[name] {
+			delete(scraperStates, name)
+		}
+	}
+	evictExpiredStates(time.Now())
+	scraperStatesMu.Unlock()
+
+	scrapedMetaMu.Lock()
+	for name := range scrapedMeta {
+		if !names[name] {
+			delete(scrapedMeta, name)
+		}
+	}
+	scrapedMetaMu.Unlock()
+
+	limitedScrapersMu.Lock()
+	for name := range limitedScrapers {
+		if !names[name] {
+			delete(limitedScrapers, name)
+		}
+	}
+	limitedScrapersMu.Unlock()
+
+	tlsClientsMu.Lock()
+	for key, client := range tlsClients {
+		if !clientKeys[key] {
+			client.CloseIdleConnections()
+			delete(tlsClients, key)
+		}
+	}
+	tlsClientsMu.Unlock()
+
+	closeUnusedSQLDBs(dbKeys)
+}
+
+// StateSize returns the number of entries held in per-scraper state maps
+func StateSize() int {
+	scraperStatesMu.Lock()
+	evictExpiredStates(time.Now())
+	size := len(scraperStates)
+	scraperStatesMu.Unlock()
+
+	scrapedMetaMu.RLock()
+	size += len(scrapedMeta)
+	scrapedMetaMu.RUnlock()
+
+	limitedScrapersMu.Lock()
+	size += len(limitedScrapers)
+	limitedScrapersMu.Unlock()
+
+	tlsClientsMu.Lock()
+	size += len(tlsClients)
+	tlsClientsMu.Unlock()
+
+	sqlDBsMu.Lock()
+	size += len(sqlDBs)
+	sqlDBsMu.Unlock()
+	return size
+}
+
+func tlsClientKey(source config.SourceConfig) string {
+	return fmt.Sprintf("%s|%s|%s|%t", source.ClientCert, source.ClientKey, source.CACert, source.InsecureSkipVerify)
+}
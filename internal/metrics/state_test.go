@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/parsers"
+)
+
+func TestPruneScrapedMetadata(t *testing.T) {
+	c := &config.Config{Scrapers: []config.ScraperConfig{{Name: "kept"}, {Name: "removed"}}}
+	pruneScraperState(c)
+	meta := map[string]parsers.PromMetadata{"up": {Help: "Target up"}}
+	storeScrapedMetadata("kept", meta)
+	storeScrapedMetadata("removed", meta)
+	defer pruneScraperState(&config.Config{})
+
+	before := StateSize()
+	c.Scrapers = c.Scrapers[:1]
+	pruneScraperState(c)
+
+	if ScrapedMetadata("removed") != nil {
+		t.Error("metadata of a removed scraper survived the reload")
+	}
+	if ScrapedMetadata("kept") == nil {
+		t.Error("metadata of a configured scraper was dropped")
+	}
+	if got := StateSize(); got != before-1 {
+		t.Errorf("StateSize = %d after pruning, want %d", got, before-1)
+	}
+}
+
+func TestEvictOnlyUnconfiguredStates(t *testing.T) {
+	c := &config.Config{
+		Server:   config.ServerConfig{ScraperStateTTLSeconds: 60},
+		Scrapers: []config.ScraperConfig{{Name: "configured"}},
+	}
+	pruneScraperState(c)
+	defer pruneScraperState(&config.Config{})
+
+	recordScrapeResult(config.ScraperConfig{Name: "configured"}, errors.New("connection refused"))
+	recordScrapeResult(config.ScraperConfig{Name: "removed"}, nil)
+
+	// Both idle past the TTL
+	scraperStatesMu.Lock()
+	evictExpiredStates(time.Now().Add(2 * time.Minute))
+	scraperStatesMu.Unlock()
+
+	if up, ok := ScraperUp("configured"); !ok || up {
+		t.Errorf("configured scraper: up=%v ok=%v, want its down state kept", up, ok)
+	}
+	if _, ok := ScraperUp("removed"); ok {
+		t.Error("state of an unconfigured scraper was not evicted")
+	}
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// Characters StatsD uses as separators or that aggregators commonly reject
+var statsdUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.\-]+`)
+
+// Keeps packets under a typical Ethernet MTU once IP and UDP headers are added
+const defaultStatsDPacketBytes = 1432
+
+// StartStatsD sends the numeric values in the output of collect to a StatsD
+// server as gauges every interval until Shutdown. Names are the dotted path
+// to each value, so they start with the system or scraper name.
+func StartStatsD(sd config.StatsDConfig, collect func() map[string]interface{}) {
+	interval := 15 * time.Second
+	if sd.IntervalSeconds > 0 {
+		interval = time.Duration(sd.IntervalSeconds) * time.Second
+	}
+	maxPacket := defaultStatsDPacketBytes
+	if sd.MaxPacketBytes > 0 {
+		maxPacket = sd.MaxPacketBytes
+	}
+
+	log.Printf("StatsD enabled: sending gauges to %s every %s", sd.Address, interval)
+	goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				lines := statsdLines(collect(), strings.Trim(sd.Prefix, "."))
+				if err := sendStatsD(sd.Address, statsdPackets(lines, maxPacket)); err != nil {
+					log.Printf("WARN: StatsD push to %s failed: %v", sd.Address, err)
+				}
+			}
+		}
+	})
+}
+
+// statsdLines renders every numeric value in result as a gauge, sorted by
+// name. Negative values are preceded by a reset to 0, since a leading sign
+// would otherwise be read as a delta.
+func statsdLines(result map[string]interface{}, prefix string) []string {
+	flat := utils.Flatten(result, "")
+
+	names := make([]string, 0, len(flat))
+	values := make(map[string]float64, len(flat))
+	for key, value := range flat {
+		// Timestamp-wrapped values are sent as the plain value
+		if wrapped, ok := value.(map[string]interface{}); ok {
+			value = wrapped["value"]
+		}
+		if !utils.IsNumber(value) {
+			continue
+		}
+		num, _ := utils.ToFloat64(value)
+
+		name := strings.Trim(statsdUnsafe.ReplaceAllString(key, "_"), "_.")
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if _, exists := values[name]; !exists {
+			names = append(names, name)
+		}
+		values[name] = num
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		value := values[name]
+		if value < 0 {
+			lines = append(lines, name+":0|g")
+		}
+		lines = append(lines, name+":"+strconv.FormatFloat(value, 'f', -1, 64)+"|g")
+	}
+	return lines
+}
+
+// statsdPackets joins lines with newlines into packets of at most
+// maxBytes. A line longer than maxBytes gets a packet of its own.
+func statsdPackets(lines []string, maxBytes int) [][]byte {
+	var packets [][]byte
+	var current []byte
+	for _, line := range lines {
+		if len(current) > 0 && len(current)+1+len(line) > maxBytes {
+			packets = append(packets, current)
+			current = nil
+		}
+		if len(current) > 0 {
+			current = append(current, '\n')
+		}
+		current = append(current, line...)
+	}
+	if len(current) > 0 {
+		packets = append(packets, current)
+	}
+	return packets
+}
+
+func sendStatsD(address string, packets [][]byte) error {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, packet := range packets {
+		if _, err := conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,6 +2,12 @@
 package metrics
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,25 +26,71 @@ import (
 
 var cfg *config.Config
 
-// Store previous values for rate calculations
-type previousMetrics struct {
-	diskReadBytes  uint64
-	diskWriteBytes uint64
-	netBytesSent   uint64
-	netBytesRecv   uint64
-	timestamp      int64
-}
+// Upper bound on a single system collection
+var collectionTimeout time.Duration
+
+// Oldest metrics CollectSystem will return; 0 disables the check
+var maxStale time.Duration
 
-var prevMetrics previousMetrics
+// A collected snapshot and when it was taken. Swapped as a single pointer
+// so readers never see a map paired with another collection's timestamp.
+type cacheEntry struct {
+	metrics   map[string]interface{}
+	timestamp int64
+}
 
-// Cache for collected metrics
+// Cache for collected metrics. Readers only load the pointer; the
+// collecting goroutine holds collectionMutex and swaps it.
 var (
-	cachedMetrics   atomic.Value
-	cacheTimestamp  atomic.Int64
+	cache           atomic.Pointer[cacheEntry]
 	cacheTTL        int64 // Store as nanoseconds for faster comparison
 	collectionMutex sync.Mutex
 )
 
+// KnownSystemMetrics lists every system metric name doActualCollection can
+// produce. Config entries are validated against it.
+var KnownSystemMetrics = []string{
+	// CPU
+	"cpu_usage_percent", "cpu_usage_per_core", "cpu_count", "cpu_count_physical",
+	"cpu_load_1min", "cpu_load_5min", "cpu_load_15min",
+	"cpu_load_1min_per_core", "cpu_load_5min_per_core", "cpu_load_15min_per_core",
+	"cpu_frequency_mhz", "cpu_frequency_per_core_mhz",
+	// Memory
+	"ram_usage_percent", "available_ram_mb", "total_ram_mb", "ram_cached_mb", "ram_buffers_mb",
+	"swap_usage_percent", "swap_total_mb", "swap_used_mb",
+	// Disk
+	"disk_usage_percent", "available_disk_gb", "total_disk_gb", "inode_usage_percent",
+	"inodes_total", "inodes_used", "inodes_free",
+	"disk_usage_weighted_percent",
+	"disk_read_bytes", "disk_write_bytes", "disk_read_bytes_per_sec", "disk_write_bytes_per_sec",
+	"disk_read_count", "disk_write_count",
+	// Network
+	"network_bytes_sent", "network_bytes_recv", "network_bytes_sent_per_sec", "network_bytes_recv_per_sec",
+	"network_packets_sent", "network_packets_recv", "network_errors_in", "network_errors_out",
+	"active_connections", "listening_ports",
+	// System info
+	"system_uptime_seconds", "boot_time_unix", "os_platform", "os_version", "hostname",
+	"kernel_version", "process_count", "open_file_descriptors", "max_file_descriptors",
+	// Power
+	"battery_percent", "battery_charging", "power_plugged",
+	// Clock, requires ntp_server
+	"clock_offset_ms",
+}
+
+// Lookup set built from KnownSystemMetrics
+var knownSystemMetrics = func() map[string]bool {
+	known := make(map[string]bool, len(KnownSystemMetrics))
+	for _, metric := range KnownSystemMetrics {
+		known[metric] = true
+	}
+	return known
+}()
+
+// IsKnownSystemMetric reports whether name is a valid system metric
+func IsKnownSystemMetric(name string) bool {
+	return knownSystemMetrics[name]
+}
+
 // Pre-parsed metric lookup
 var requestedMetrics map[string]bool
 
@@ -49,24 +101,38 @@ type metricGroups struct {
 	memory       bool
 	swap         bool
 	diskUsage    bool
+	diskWeighted bool
 	diskIO       bool
 	network      bool
 	netConn      bool
+	listenPorts  bool
 	processCount bool
 	hostInfo     bool
+	fileDesc     bool
+	battery      bool
+	clock        bool
+	cpuFreq      bool
 }
 
 var groups metricGroups
 
-// Constants for conversions (pre-calculated)
-const (
-	bytesToMB = 1.0 / 1048576.0
-	bytesToGB = 1.0 / 1073741824.0
-)
+// Unit for byte-size metrics; empty keeps each metric's default unit
+var byteUnit string
+
+// Decimal places used when rounding system metrics
+var precision = 2
+
+// round applies the configured system precision
+func round(val float64) float64 {
+	return utils.Round(val, precision)
+}
+
+// Sampling window for CPU usage
+const cpuSampleWindow = 100 * time.Millisecond
 
 func Init(c *config.Config) {
 	cfg = c
-	atomic.StoreInt64(&prevMetrics.timestamp, time.Now().UnixNano())
+	initBackground()
 	
 	// Set cache TTL as nanoseconds for faster comparison
 	if c.System.CacheTTL > 0 {
@@ -75,21 +141,81 @@ func Init(c *config.Config) {
 		cacheTTL = 15 * 1e9
 	}
 	
-	// Pre-parse requested metrics into a map (done once at startup)
-	requestedMetrics = make(map[string]bool, len(c.System.Metrics))
+	// Spread cache expiry across a fleet by offsetting the TTL a random
+	// amount, picked once per process
+	if c.System.CacheJitterPercent > 0 {
+		jitter := float64(c.System.CacheJitterPercent) / 100
+		cacheTTL = int64(float64(cacheTTL) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+	
+	maxStale = time.Duration(c.System.MaxStaleSeconds) * time.Second
+	rateWindow = time.Duration(c.System.RateWindowSeconds) * time.Second
+	if maxStale > 0 && maxStale < time.Duration(cacheTTL) {
+		log.Printf("WARN: max_stale_seconds (%v) is shorter than the cache TTL; cached metrics will be rejected before they expire", maxStale)
+	}
+	
+	if c.System.CollectionTimeoutSeconds > 0 {
+		collectionTimeout = time.Duration(c.System.CollectionTimeoutSeconds) * time.Second
+	} else {
+		collectionTimeout = 10 * time.Second
+	}
+	
+	precision = 2
+	if c.System.Precision != nil {
+		precision = *c.System.Precision
+	}
+	
+	byteUnit = c.System.ByteUnit
+	if byteUnit != "" && !utils.IsByteUnit(byteUnit) {
+		log.Printf("WARN: Unknown byte_unit '%s', using default units", byteUnit)
+		byteUnit = ""
+	}
+	
+	initDiskDiscovery()
+	initProcessTracking()
+	initRelabelRules(c.Scrapers)
+	initOutboundLimit(c.Server.MaxScrapesPerSecond)
+	warnInsecureScrapers(c.Scrapers)
+	pruneScraperState(c)
+	
+	// Warn about names that would otherwise be silently ignored
 	for _, metric := range c.System.Metrics {
+		if !IsKnownSystemMetric(metric) {
+			log.Printf("WARN: Unknown system metric '%s' in config, it will not be collected", metric)
+		}
+	}
+	for _, metric := range c.System.ExcludeMetrics {
+		if !IsKnownSystemMetric(metric) {
+			log.Printf("WARN: Unknown system metric '%s' in exclude_metrics", metric)
+		}
+	}
+	
+	// Pre-parse requested metrics into a map (done once at startup)
+	requested := c.System.Metrics
+	if c.System.AllMetrics {
+		requested = KnownSystemMetrics
+	}
+	requestedMetrics = make(map[string]bool, len(requested))
+	for _, metric := range requested {
 		requestedMetrics[metric] = true
 	}
+	for _, metric := range c.System.ExcludeMetrics {
+		delete(requestedMetrics, metric)
+	}
 	
 	// Pre-parse metric groups
 	groups.cpuUsage = requestedMetrics["cpu_usage_percent"] || requestedMetrics["cpu_usage_per_core"]
 	groups.cpuInfo = requestedMetrics["cpu_count"] || requestedMetrics["cpu_count_physical"] ||
-		requestedMetrics["cpu_load_1min"] || requestedMetrics["cpu_load_5min"] || requestedMetrics["cpu_load_15min"]
+		requestedMetrics["cpu_load_1min"] || requestedMetrics["cpu_load_5min"] || requestedMetrics["cpu_load_15min"] ||
+		requestedMetrics["cpu_load_1min_per_core"] || requestedMetrics["cpu_load_5min_per_core"] ||
+		requestedMetrics["cpu_load_15min_per_core"]
 	groups.memory = requestedMetrics["ram_usage_percent"] || requestedMetrics["available_ram_mb"] ||
 		requestedMetrics["total_ram_mb"] || requestedMetrics["ram_cached_mb"] || requestedMetrics["ram_buffers_mb"]
 	groups.swap = requestedMetrics["swap_usage_percent"] || requestedMetrics["swap_total_mb"] || requestedMetrics["swap_used_mb"]
 	groups.diskUsage = requestedMetrics["disk_usage_percent"] || requestedMetrics["available_disk_gb"] ||
-		requestedMetrics["total_disk_gb"] || requestedMetrics["inode_usage_percent"]
+		requestedMetrics["total_disk_gb"] || requestedMetrics["inode_usage_percent"] ||
+		requestedMetrics["inodes_total"] || requestedMetrics["inodes_used"] || requestedMetrics["inodes_free"]
+	groups.diskWeighted = requestedMetrics["disk_usage_weighted_percent"]
 	groups.diskIO = requestedMetrics["disk_read_bytes"] || requestedMetrics["disk_write_bytes"] ||
 		requestedMetrics["disk_read_bytes_per_sec"] || requestedMetrics["disk_write_bytes_per_sec"] ||
 		requestedMetrics["disk_read_count"] || requestedMetrics["disk_write_count"]
@@ -98,51 +224,186 @@ func Init(c *config.Config) {
 		requestedMetrics["network_packets_sent"] || requestedMetrics["network_packets_recv"] ||
 		requestedMetrics["network_errors_in"] || requestedMetrics["network_errors_out"]
 	groups.netConn = requestedMetrics["active_connections"]
+	groups.listenPorts = requestedMetrics["listening_ports"]
 	groups.processCount = requestedMetrics["process_count"]
+	groups.fileDesc = requestedMetrics["open_file_descriptors"] || requestedMetrics["max_file_descriptors"]
+	groups.cpuFreq = requestedMetrics["cpu_frequency_mhz"] || requestedMetrics["cpu_frequency_per_core_mhz"]
+	groups.battery = requestedMetrics["battery_percent"] || requestedMetrics["battery_charging"] || requestedMetrics["power_plugged"]
+	groups.clock = requestedMetrics["clock_offset_ms"] && c.System.NTPServer != ""
+	if requestedMetrics["clock_offset_ms"] && c.System.NTPServer == "" {
+		log.Printf("WARN: clock_offset_ms requires system.ntp_server, it will not be collected")
+	}
+	resetClockOffset()
 	groups.hostInfo = requestedMetrics["system_uptime_seconds"] || requestedMetrics["boot_time_unix"] ||
 		requestedMetrics["os_platform"] || requestedMetrics["os_version"] ||
 		requestedMetrics["hostname"] || requestedMetrics["kernel_version"]
 	
-	cacheTimestamp.Store(0)
+	primeRateCounters()
+	initSmoothing(c.System.SmoothingFactor)
+
+	cache.Store(nil)
+
+	// Populate the cache in the background so the first request doesn't
+	// pay for a full collection
+	if c.System.Enabled && c.System.WarmOnStart {
+		go CollectSystem(context.Background())
+	}
 }
 
-func CollectSystem() map[string]interface{} {
-	// Fast path: return cached metrics if still valid
-	cachedTime := cacheTimestamp.Load()
-	nowNano := time.Now().UnixNano()
-	
-	if cachedTime > 0 && (nowNano-cachedTime) < cacheTTL {
-		if cached := cachedMetrics.Load(); cached != nil {
-			return cached.(map[string]interface{})
+// Per-device disk I/O counters; a variable so tests can substitute a fake
+var diskIOCounters = func(ctx context.Context) (map[string]disk.IOCountersStat, error) {
+	return disk.IOCountersWithContext(ctx)
+}
+
+// primeRateCounters records baseline disk and network counters so the first
+// collection reports a rate over the interval since startup instead of
+// skipping it. Init runs it under reloadMu, so the reads are bounded by
+// collection_timeout like a collection.
+func primeRateCounters() {
+	for _, counter := range []*rateCounter{&diskReadRate, &diskWriteRate, &netSentRate, &netRecvRate} {
+		counter.reset()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectionTimeout)
+	defer cancel()
+
+	now := time.Now().UnixNano()
+	if groups.diskIO {
+		if counters, err := diskIOCounters(ctx); err == nil {
+			var totalRead, totalWrite uint64
+			for _, counter := range counters {
+				totalRead += counter.ReadBytes
+				totalWrite += counter.WriteBytes
+			}
+			diskReadRate.observe(now, totalRead)
+			diskWriteRate.observe(now, totalWrite)
 		}
 	}
+	if groups.network {
+		if c, ok := networkTotals(ctx); ok {
+			netSentRate.observe(now, c.BytesSent)
+			netRecvRate.observe(now, c.BytesRecv)
+		}
+	}
+}
+
+// A system collection in progress that concurrent callers wait on
+type systemCall struct {
+	done        chan struct{}
+	metrics     map[string]interface{}
+	collectedAt time.Time
+	err         error
+}
+
+// The in-flight collection, nil when none is running
+var (
+	systemFlight   *systemCall
+	systemFlightMu sync.Mutex
+)
+
+// CollectSystem returns the cached system metrics, collecting them first if
+// the cache has expired. If the metrics are older than max_stale_seconds
+// they are not returned and the error says how old they are.
+//
+// The collection is shared by every caller and bounded only by
+// collection_timeout, so a caller whose ctx ends stops waiting and gets
+// ctx's error while the collection carries on for the others.
+func CollectSystem(ctx context.Context) (map[string]interface{}, time.Time, error) {
+	// Fast path: return cached metrics if still valid
+	if entry := cache.Load(); entry != nil && time.Now().UnixNano()-entry.timestamp < cacheTTL {
+		return checkStale(entry)
+	}
+
+	// Slow path: join the running collection or start one
+	systemFlightMu.Lock()
+	call := systemFlight
+	if call == nil {
+		call = &systemCall{done: make(chan struct{})}
+		systemFlight = call
+		go runSystemCollection(call)
+	}
+	systemFlightMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.metrics, call.collectedAt, call.err
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+}
+
+// runSystemCollection fills the cache, if it has expired, and publishes
+// the result to call's waiters
+func runSystemCollection(call *systemCall) {
+	defer func() {
+		systemFlightMu.Lock()
+		systemFlight = nil
+		systemFlightMu.Unlock()
+		close(call.done)
+	}()
 
-	// Slow path: need to collect new metrics
 	collectionMutex.Lock()
 	defer collectionMutex.Unlock()
 
 	// Double-check cache after acquiring lock
-	cachedTime = cacheTimestamp.Load()
-	nowNano = time.Now().UnixNano()
-	if cachedTime > 0 && (nowNano-cachedTime) < cacheTTL {
-		if cached := cachedMetrics.Load(); cached != nil {
-			return cached.(map[string]interface{})
-		}
+	nowNano := time.Now().UnixNano()
+	if entry := cache.Load(); entry != nil && nowNano-entry.timestamp < cacheTTL {
+		call.metrics, call.collectedAt, call.err = checkStale(entry)
+		return
 	}
 
 	// Actually collect metrics
-	metrics := doActualCollection(nowNano)
+	metrics := doActualCollection(context.Background(), nowNano)
+
+	// Damp sampling noise before anything derives from it
+	applySmoothing(metrics)
+
+	// Compute derived metrics from the collected values
+	applyDerived(metrics)
+
+	// Nest by category if requested
+	if cfg.System.Grouped {
+		metrics = groupMetrics(metrics)
+	}
 
 	// Update cache atomically
-	cachedMetrics.Store(metrics)
-	cacheTimestamp.Store(nowNano)
+	entry := &cacheEntry{metrics: metrics, timestamp: nowNano}
+	cache.Store(entry)
 
-	return metrics
+	call.metrics, call.collectedAt, call.err = checkStale(entry)
+}
+
+// checkStale enforces max_stale_seconds on a cache entry. The timestamp is
+// taken when collection starts, so a collection that runs long ages its own
+// result.
+func checkStale(entry *cacheEntry) (map[string]interface{}, time.Time, error) {
+	collectedAt := time.Unix(0, entry.timestamp)
+	if age := time.Since(collectedAt); maxStale > 0 && age > maxStale {
+		return nil, collectedAt, fmt.Errorf("system metrics are %.1fs old, exceeding max_stale_seconds (%.0fs)", age.Seconds(), maxStale.Seconds())
+	}
+	return entry.metrics, collectedAt, nil
+}
+
+// CacheState reports when the cached system metrics were collected and how
+// long they stay cached. ok is false when nothing is cached or the entry
+// has expired.
+func CacheState() (collectedAt time.Time, ttl time.Duration, ok bool) {
+	ttl = time.Duration(cacheTTL)
+	entry := cache.Load()
+	if entry == nil || time.Now().UnixNano()-entry.timestamp >= cacheTTL {
+		return time.Time{}, ttl, false
+	}
+	return time.Unix(0, entry.timestamp), ttl, true
+}
+
+// InvalidateCache forces the next CollectSystem call to collect fresh metrics
+func InvalidateCache() {
+	cache.Store(nil)
 }
 
-func doActualCollection(nowNano int64) map[string]interface{} {
+func doActualCollection(ctx context.Context, nowNano int64) map[string]interface{} {
 	// Pre-allocate map with exact capacity based on requested metrics
-	capacity := len(cfg.System.Metrics)
+	capacity := len(requestedMetrics)
 	metrics := make(map[string]interface{}, capacity)
 	
 	// Use a pool of result channels to avoid allocations
@@ -153,67 +414,107 @@ func doActualCollection(nowNano int64) map[string]interface{} {
 	resultChan := make(chan result, capacity)
 	
 	var wg sync.WaitGroup
-	
-	// Read previous metrics atomically
-	prevTimestamp := atomic.LoadInt64(&prevMetrics.timestamp)
-	timeDelta := float64(nowNano-prevTimestamp) * 1e-9
-	prevDiskRead := atomic.LoadUint64(&prevMetrics.diskReadBytes)
-	prevDiskWrite := atomic.LoadUint64(&prevMetrics.diskWriteBytes)
-	prevNetSent := atomic.LoadUint64(&prevMetrics.netBytesSent)
-	prevNetRecv := atomic.LoadUint64(&prevMetrics.netBytesRecv)
 
+	// Bound the whole collection so one hung collector can't block /metrics
+	ctx, cancel := context.WithTimeout(ctx, collectionTimeout)
+	defer cancel()
+
+	// Track collectors still running so a timeout can report them, and
+	// how long each finished one took
+	var pendingMu sync.Mutex
+	pending := make(map[string]bool)
+	timings := make(map[string]float64)
+	start := time.Now()
+
+	// Tiny hosts can run collectors one at a time instead
+	sequential := cfg.System.SequentialCollection
+
+	// Helper to launch a named collector goroutine
+	run := func(name string, fn func()) {
+		if sequential {
+			// A collector can't be interrupted, but once the timeout has
+			// passed the remaining ones are skipped
+			if ctx.Err() != nil {
+				pending[name] = true
+				return
+			}
+			collectorStart := time.Now()
+			fn()
+			timings[name] = durationMs(time.Since(collectorStart))
+			return
+		}
+
+		pendingMu.Lock()
+		pending[name] = true
+		pendingMu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collectorStart := time.Now()
+			defer func() {
+				pendingMu.Lock()
+				delete(pending, name)
+				timings[name] = durationMs(time.Since(collectorStart))
+				pendingMu.Unlock()
+			}()
+			fn()
+		}()
+	}
+	
 	// Helper to send metrics to channel
+	// Collectors that outlive the timeout drop their results instead of blocking
 	send := func(key string, value interface{}) {
-		resultChan <- result{key, value}
+		if sequential {
+			metrics[key] = value
+			return
+		}
+		select {
+		case resultChan <- result{key, value}:
+		case <-ctx.Done():
+		}
 	}
 
 	// CPU metrics
 	if groups.cpuUsage {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
-			wantPercent := requestedMetrics["cpu_usage_percent"]
-			wantPerCore := requestedMetrics["cpu_usage_per_core"]
-			
-			if wantPercent && wantPerCore {
-				// Collect per-core and calculate average
-				if percent, err := cpu.Percent(100*time.Millisecond, true); err == nil && len(percent) > 0 {
-					var total float64
-					coreMetrics := make([]float64, len(percent))
-					for i, p := range percent {
-						rounded := utils.Round(p, 2)
-						coreMetrics[i] = rounded
-						total += rounded
-					}
-					send("cpu_usage_per_core", coreMetrics)
-					send("cpu_usage_percent", utils.Round(total/float64(len(percent)), 2))
-				}
-			} else if wantPercent {
-				if percent, err := cpu.Percent(100*time.Millisecond, false); err == nil && len(percent) > 0 {
-					send("cpu_usage_percent", utils.Round(percent[0], 2))
-				}
-			} else if wantPerCore {
-				if percent, err := cpu.Percent(100*time.Millisecond, true); err == nil {
-					coreMetrics := make([]float64, len(percent))
-					for i, p := range percent {
-						coreMetrics[i] = utils.Round(p, 2)
-					}
-					send("cpu_usage_per_core", coreMetrics)
-				}
+		run("cpu_usage", func() {
+			// Take a single per-core sample and derive the aggregate from it,
+			// so enabling both metrics doesn't double the sampling delay
+			percent, err := cpu.PercentWithContext(ctx, cpuSampleWindow, true)
+			if err != nil || len(percent) == 0 {
+				return
 			}
-		}()
+
+			var total float64
+			coreMetrics := make([]float64, len(percent))
+			for i, p := range percent {
+				coreMetrics[i] = round(p)
+				total += p
+			}
+
+			if requestedMetrics["cpu_usage_per_core"] {
+				send("cpu_usage_per_core", coreMetrics)
+			}
+			if requestedMetrics["cpu_usage_percent"] {
+				send("cpu_usage_percent", round(total/float64(len(percent))))
+			}
+		})
 	}
 
 	// CPU info and load
 	if groups.cpuInfo {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
-			if requestedMetrics["cpu_count"] {
+		run("cpu_info", func() {
+			wantPerCore := requestedMetrics["cpu_load_1min_per_core"] || requestedMetrics["cpu_load_5min_per_core"] ||
+				requestedMetrics["cpu_load_15min_per_core"]
+
+			// Logical core count is shared by cpu_count and the per-core loads
+			var logicalCores int
+			if requestedMetrics["cpu_count"] || wantPerCore {
 				if count, err := cpu.Counts(true); err == nil {
-					send("cpu_count", count)
+					logicalCores = count
+					if requestedMetrics["cpu_count"] {
+						send("cpu_count", count)
+					}
 				}
 			}
 			if requestedMetrics["cpu_count_physical"] {
@@ -222,99 +523,124 @@ func doActualCollection(nowNano int64) map[string]interface{} {
 				}
 			}
 			
-			needLoad := requestedMetrics["cpu_load_1min"] || requestedMetrics["cpu_load_5min"] || requestedMetrics["cpu_load_15min"]
+			needLoad := requestedMetrics["cpu_load_1min"] || requestedMetrics["cpu_load_5min"] || requestedMetrics["cpu_load_15min"] || wantPerCore
 			if needLoad {
 				if avg, err := load.Avg(); err == nil {
 					if requestedMetrics["cpu_load_1min"] {
-						send("cpu_load_1min", utils.Round(avg.Load1, 2))
+						send("cpu_load_1min", round(avg.Load1))
 					}
 					if requestedMetrics["cpu_load_5min"] {
-						send("cpu_load_5min", utils.Round(avg.Load5, 2))
+						send("cpu_load_5min", round(avg.Load5))
 					}
 					if requestedMetrics["cpu_load_15min"] {
-						send("cpu_load_15min", utils.Round(avg.Load15, 2))
+						send("cpu_load_15min", round(avg.Load15))
+					}
+
+					// Load normalized by core count, comparable across hosts
+					if logicalCores > 0 {
+						cores := float64(logicalCores)
+						if requestedMetrics["cpu_load_1min_per_core"] {
+							send("cpu_load_1min_per_core", round(avg.Load1/cores))
+						}
+						if requestedMetrics["cpu_load_5min_per_core"] {
+							send("cpu_load_5min_per_core", round(avg.Load5/cores))
+						}
+						if requestedMetrics["cpu_load_15min_per_core"] {
+							send("cpu_load_15min_per_core", round(avg.Load15/cores))
+						}
 					}
 				}
 			}
-		}()
+		})
 	}
 
 	// Memory metrics
 	if groups.memory {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
+		run("memory", func() {
 			if v, err := mem.VirtualMemory(); err == nil {
 				if requestedMetrics["ram_usage_percent"] {
-					send("ram_usage_percent", utils.Round(v.UsedPercent, 2))
+					send("ram_usage_percent", round(v.UsedPercent))
 				}
 				if requestedMetrics["available_ram_mb"] {
-					send("available_ram_mb", utils.Round(float64(v.Available)*bytesToMB, 2))
+					sendSize(send, "available_ram_mb", v.Available)
 				}
 				if requestedMetrics["total_ram_mb"] {
-					send("total_ram_mb", utils.Round(float64(v.Total)*bytesToMB, 2))
+					sendSize(send, "total_ram_mb", v.Total)
 				}
 				if requestedMetrics["ram_cached_mb"] {
-					send("ram_cached_mb", utils.Round(float64(v.Cached)*bytesToMB, 2))
+					sendSize(send, "ram_cached_mb", v.Cached)
 				}
 				if requestedMetrics["ram_buffers_mb"] {
-					send("ram_buffers_mb", utils.Round(float64(v.Buffers)*bytesToMB, 2))
+					sendSize(send, "ram_buffers_mb", v.Buffers)
 				}
 			}
-		}()
+		})
 	}
 
 	// Swap metrics
 	if groups.swap {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
+		run("swap", func() {
 			if s, err := mem.SwapMemory(); err == nil {
 				if requestedMetrics["swap_usage_percent"] {
-					send("swap_usage_percent", utils.Round(s.UsedPercent, 2))
+					send("swap_usage_percent", round(s.UsedPercent))
 				}
 				if requestedMetrics["swap_total_mb"] {
-					send("swap_total_mb", utils.Round(float64(s.Total)*bytesToMB, 2))
+					sendSize(send, "swap_total_mb", s.Total)
 				}
 				if requestedMetrics["swap_used_mb"] {
-					send("swap_used_mb", utils.Round(float64(s.Used)*bytesToMB, 2))
+					sendSize(send, "swap_used_mb", s.Used)
 				}
 			}
-		}()
+		})
 	}
 
 	// Disk usage metrics
 	if groups.diskUsage {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
+		run("disk_usage", func() {
 			if usage, err := disk.Usage("/"); err == nil {
 				if requestedMetrics["disk_usage_percent"] {
-					send("disk_usage_percent", utils.Round(usage.UsedPercent, 2))
+					send("disk_usage_percent", round(usage.UsedPercent))
 				}
 				if requestedMetrics["available_disk_gb"] {
-					send("available_disk_gb", utils.Round(float64(usage.Free)*bytesToGB, 2))
+					sendSize(send, "available_disk_gb", usage.Free)
 				}
 				if requestedMetrics["total_disk_gb"] {
-					send("total_disk_gb", utils.Round(float64(usage.Total)*bytesToGB, 2))
+					sendSize(send, "total_disk_gb", usage.Total)
 				}
 				if requestedMetrics["inode_usage_percent"] {
-					send("inode_usage_percent", utils.Round(usage.InodesUsedPercent, 2))
+					send("inode_usage_percent", round(usage.InodesUsedPercent))
+				}
+				if requestedMetrics["inodes_total"] {
+					send("inodes_total", usage.InodesTotal)
+				}
+				if requestedMetrics["inodes_used"] {
+					send("inodes_used", usage.InodesUsed)
+				}
+				if requestedMetrics["inodes_free"] {
+					send("inodes_free", usage.InodesFree)
 				}
 			}
-		}()
+		})
+	}
+
+	// Per-mount disk usage
+	if groups.diskUsage && cfg.System.AutoDiscoverDisks {
+		run("disk_mounts", func() {
+			collectMountUsage(ctx, send)
+		})
+	}
+
+	// Size-weighted usage across all real filesystems
+	if groups.diskWeighted {
+		run("disk_weighted", func() {
+			collectWeightedDiskUsage(ctx, send)
+		})
 	}
 
 	// Disk I/O metrics
 	if groups.diskIO {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
-			if counters, err := disk.IOCounters(); err == nil {
+		run("disk_io", func() {
+			if counters, err := diskIOCounters(ctx); err == nil {
 				var totalRead, totalWrite, totalReads, totalWrites uint64
 				for _, counter := range counters {
 					totalRead += counter.ReadBytes
@@ -336,29 +662,25 @@ func doActualCollection(nowNano int64) map[string]interface{} {
 					send("disk_write_count", totalWrites)
 				}
 				
-				if requestedMetrics["disk_read_bytes_per_sec"] && prevDiskRead > 0 && timeDelta > 0 {
-					bytesPerSec := float64(totalRead-prevDiskRead) / timeDelta
-					send("disk_read_bytes_per_sec", utils.Round(bytesPerSec, 2))
+				// Past the timeout the values would be dropped anyway, and a
+				// late sample must not land in the rate history
+				if ctx.Err() != nil {
+					return
 				}
-				if requestedMetrics["disk_write_bytes_per_sec"] && prevDiskWrite > 0 && timeDelta > 0 {
-					bytesPerSec := float64(totalWrite-prevDiskWrite) / timeDelta
-					send("disk_write_bytes_per_sec", utils.Round(bytesPerSec, 2))
+				if bytesPerSec, ok := diskReadRate.observe(nowNano, totalRead); ok && requestedMetrics["disk_read_bytes_per_sec"] {
+					send("disk_read_bytes_per_sec", round(bytesPerSec))
+				}
+				if bytesPerSec, ok := diskWriteRate.observe(nowNano, totalWrite); ok && requestedMetrics["disk_write_bytes_per_sec"] {
+					send("disk_write_bytes_per_sec", round(bytesPerSec))
 				}
-				
-				atomic.StoreUint64(&prevMetrics.diskReadBytes, totalRead)
-				atomic.StoreUint64(&prevMetrics.diskWriteBytes, totalWrite)
 			}
-		}()
+		})
 	}
 
 	// Network metrics
 	if groups.network {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
-			if counters, err := net.IOCounters(false); err == nil && len(counters) > 0 {
-				c := counters[0]
+		run("network", func() {
+			if c, ok := networkTotals(ctx); ok {
 				
 				if requestedMetrics["network_bytes_sent"] {
 					send("network_bytes_sent", c.BytesSent)
@@ -379,49 +701,115 @@ func doActualCollection(nowNano int64) map[string]interface{} {
 					send("network_errors_out", c.Errout)
 				}
 				
-				if requestedMetrics["network_bytes_sent_per_sec"] && prevNetSent > 0 && timeDelta > 0 {
-					bytesPerSec := float64(c.BytesSent-prevNetSent) / timeDelta
-					send("network_bytes_sent_per_sec", utils.Round(bytesPerSec, 2))
+				if ctx.Err() != nil {
+					return
 				}
-				if requestedMetrics["network_bytes_recv_per_sec"] && prevNetRecv > 0 && timeDelta > 0 {
-					bytesPerSec := float64(c.BytesRecv-prevNetRecv) / timeDelta
-					send("network_bytes_recv_per_sec", utils.Round(bytesPerSec, 2))
+				if bytesPerSec, ok := netSentRate.observe(nowNano, c.BytesSent); ok && requestedMetrics["network_bytes_sent_per_sec"] {
+					send("network_bytes_sent_per_sec", round(bytesPerSec))
+				}
+				if bytesPerSec, ok := netRecvRate.observe(nowNano, c.BytesRecv); ok && requestedMetrics["network_bytes_recv_per_sec"] {
+					send("network_bytes_recv_per_sec", round(bytesPerSec))
 				}
-				
-				atomic.StoreUint64(&prevMetrics.netBytesSent, c.BytesSent)
-				atomic.StoreUint64(&prevMetrics.netBytesRecv, c.BytesRecv)
 			}
-		}()
+		})
 	}
 
 	// Network connections
 	if groups.netConn {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if conns, err := net.Connections("all"); err == nil {
+		run("net_connections", func() {
+			if conns, err := net.ConnectionsWithContext(ctx, "all"); err == nil {
 				send("active_connections", len(conns))
 			}
-		}()
+		})
+	}
+
+	// Listening sockets, one series per port
+	if groups.listenPorts {
+		run("listening_ports", func() {
+			collectListeningPorts(ctx, send)
+		})
 	}
 
 	// Process count
 	if groups.processCount {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if procs, err := process.Processes(); err == nil {
+		run("process_count", func() {
+			if procs, err := process.ProcessesWithContext(ctx); err == nil {
 				send("process_count", len(procs))
 			}
-		}()
+		})
+	}
+
+	// Systemd units
+	if len(cfg.System.SystemdUnits) > 0 {
+		run("systemd", func() {
+			collectSystemdUnits(ctx, send)
+		})
+	}
+
+	// File descriptors
+	if groups.fileDesc {
+		run("file_descriptors", func() {
+			if open, max, err := readFileDescriptors(); err == nil {
+				if requestedMetrics["open_file_descriptors"] {
+					send("open_file_descriptors", open)
+				}
+				if requestedMetrics["max_file_descriptors"] {
+					send("max_file_descriptors", max)
+				}
+			}
+		})
+	}
+
+	// CPU clock speed, omitted where the platform doesn't report it
+	if groups.cpuFreq {
+		run("cpu_frequency", func() {
+			freqs, err := readCPUFrequencies(ctx)
+			if err != nil || len(freqs) == 0 {
+				return
+			}
+
+			var total float64
+			coreMetrics := make([]float64, len(freqs))
+			for i, freq := range freqs {
+				coreMetrics[i] = round(freq)
+				total += freq
+			}
+
+			if requestedMetrics["cpu_frequency_per_core_mhz"] {
+				send("cpu_frequency_per_core_mhz", coreMetrics)
+			}
+			if requestedMetrics["cpu_frequency_mhz"] {
+				send("cpu_frequency_mhz", round(total/float64(len(freqs))))
+			}
+		})
+	}
+
+	// Battery, skipped entirely on hosts without one
+	if groups.battery {
+		run("battery", func() {
+			collectBattery(send)
+		})
+	}
+
+	// Clock offset from NTP, cached for ntp_cache_seconds
+	if groups.clock {
+		run("clock", func() {
+			if offset, ok := clockOffset(); ok {
+				send("clock_offset_ms", round(float64(offset.Microseconds())/1000))
+			}
+		})
+	}
+
+	// Tracked processes by name
+	if len(trackedProcesses) > 0 {
+		run("tracked_processes", func() {
+			collectTrackedProcesses(ctx, send)
+		})
 	}
 
 	// Host info
 	if groups.hostInfo {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
+		run("host_info", func() {
 			if info, err := host.Info(); err == nil {
 				if requestedMetrics["system_uptime_seconds"] {
 					send("system_uptime_seconds", float64(info.Uptime))
@@ -442,22 +830,62 @@ func doActualCollection(nowNano int64) map[string]interface{} {
 					send("kernel_version", info.KernelVersion)
 				}
 			}
-		}()
+		})
+	}
+
+	logSkipped := func() {
+		pendingMu.Lock()
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		pendingMu.Unlock()
+		sort.Strings(names)
+		log.Printf("WARN: System collection timed out after %v, skipping collectors: %s", collectionTimeout, strings.Join(names, ", "))
 	}
 
-	// Collect results from channel in a separate goroutine
+	// Collect results until every collector finishes or the timeout fires
+	done := make(chan struct{})
 	go func() {
 		wg.Wait()
-		close(resultChan)
+		close(done)
 	}()
 
-	// Collect all results from channel (no mutex needed)
-	for r := range resultChan {
-		metrics[r.key] = r.value
+
+collect:
+	for {
+		select {
+		case r := <-resultChan:
+			metrics[r.key] = r.value
+		case <-done:
+			break collect
+		case <-ctx.Done():
+			// Sequential collectors have all run or been skipped by now
+			if !sequential {
+				logSkipped()
+			}
+			break collect
+		}
+	}
+	if sequential && len(pending) > 0 {
+		logSkipped()
+	}
+
+	// Drain results that were sent before we stopped waiting. Late collectors
+	// can still send afterwards; the channel is buffered so they never block.
+	for drained := false; !drained; {
+		select {
+		case r := <-resultChan:
+			metrics[r.key] = r.value
+		default:
+			drained = true
+		}
 	}
 
-	// Update timestamp atomically
-	atomic.StoreInt64(&prevMetrics.timestamp, nowNano)
+	// Keep timings for ?debug=timing
+	pendingMu.Lock()
+	recordTimings(timings, durationMs(time.Since(start)))
+	pendingMu.Unlock()
 
 	return metrics
 }
\ No newline at end of file
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// BenchmarkCollectSystemCached measures concurrent reads of a warm cache,
+// the path every /metrics request takes between collections. Readers only
+// load the cache pointer, so throughput should scale with -cpu.
+func BenchmarkCollectSystemCached(b *testing.B) {
+	Init(&config.Config{System: config.SystemConfig{
+		Enabled:  true,
+		CacheTTL: 3600,
+		Metrics:  []string{"cpu_count"},
+	}})
+	defer Shutdown(context.Background())
+
+	if _, _, err := CollectSystem(context.Background()); err != nil {
+		b.Fatalf("CollectSystem: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			if _, _, err := CollectSystem(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
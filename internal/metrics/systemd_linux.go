@@ -0,0 +1,56 @@
+//go:build linux
+
+package metrics
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// collectSystemdUnits emits state and resource usage for each configured
+// unit via `systemctl show`. Hosts without systemd simply produce nothing.
+func collectSystemdUnits(ctx context.Context, send func(string, interface{})) {
+	for _, unit := range cfg.System.SystemdUnits {
+		props, err := systemctlShow(ctx, unit)
+		if err != nil {
+			continue
+		}
+
+		active := 0
+		if props["ActiveState"] == "active" {
+			active = 1
+		}
+		send(utils.Labeled("service_active", "unit", unit), active)
+
+		// systemd reports "[not set]" or the max uint64 when accounting is off
+		if mem, err := strconv.ParseUint(props["MemoryCurrent"], 10, 64); err == nil && mem != ^uint64(0) {
+			sendSize(send, "service_memory_mb", mem, "unit", unit)
+		}
+		if cpuNs, err := strconv.ParseUint(props["CPUUsageNSec"], 10, 64); err == nil && cpuNs != ^uint64(0) {
+			send(utils.Labeled("service_cpu_seconds", "unit", unit), round(float64(cpuNs)/1e9))
+		}
+		if restarts, err := strconv.ParseUint(props["NRestarts"], 10, 64); err == nil {
+			send(utils.Labeled("service_restarts", "unit", unit), restarts)
+		}
+	}
+}
+
+func systemctlShow(ctx context.Context, unit string) (map[string]string, error) {
+	out, err := exec.CommandContext(ctx, "systemctl", "show", unit,
+		"--property=ActiveState,MemoryCurrent,CPUUsageNSec,NRestarts").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	return props, nil
+}
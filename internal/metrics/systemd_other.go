@@ -0,0 +1,8 @@
+//go:build !linux
+
+package metrics
+
+import "context"
+
+// collectSystemdUnits is a no-op where systemd doesn't exist
+func collectSystemdUnits(ctx context.Context, send func(string, interface{})) {}
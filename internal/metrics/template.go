@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// Values available to url, fallback_url and body templates
+type sourceTemplateData struct {
+	Hostname string
+	Env      map[string]string
+	System   map[string]interface{} // last collected system metrics, empty until the first collection
+}
+
+var sourceTemplateFuncs = template.FuncMap{
+	"hostname": func() string {
+		hostname, _ := os.Hostname()
+		return hostname
+	},
+	"env": os.Getenv,
+}
+
+// renderSource resolves Go template placeholders such as {{hostname}},
+// {{.Env.REGION}} or {{.System.cpu_count}} in a url source. It runs on
+// every scrape so values track the host; sources without "{{" are
+// returned unchanged.
+func renderSource(source config.SourceConfig) (config.SourceConfig, error) {
+	if !strings.Contains(source.URL+source.FallbackURL+source.Body, "{{") {
+		return source, nil
+	}
+
+	data := sourceTemplateData{
+		Env:    make(map[string]string),
+		System: make(map[string]interface{}),
+	}
+	data.Hostname, _ = os.Hostname()
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			data.Env[name] = value
+		}
+	}
+	if entry := cache.Load(); entry != nil {
+		data.System = entry.metrics
+	}
+
+	for _, field := range []*string{&source.URL, &source.FallbackURL, &source.Body} {
+		if !strings.Contains(*field, "{{") {
+			continue
+		}
+		tmpl, err := template.New("source").Funcs(sourceTemplateFuncs).Option("missingkey=error").Parse(*field)
+		if err != nil {
+			return source, fmt.Errorf("invalid template: %v", err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return source, fmt.Errorf("template: %v", err)
+		}
+		*field = out.String()
+	}
+	return source, nil
+}
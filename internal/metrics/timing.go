@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CollectionTimings describes how long the last system collection took
+type CollectionTimings struct {
+	TotalMs      float64            `json:"total_ms"`
+	CollectorsMs map[string]float64 `json:"collectors_ms"`
+}
+
+// Timings from the most recent system collection
+var lastTimings atomic.Pointer[CollectionTimings]
+
+// recordTimings stores a copy of the per-collector timings
+func recordTimings(collectors map[string]float64, totalMs float64) {
+	copied := make(map[string]float64, len(collectors))
+	for name, ms := range collectors {
+		copied[name] = ms
+	}
+	lastTimings.Store(&CollectionTimings{TotalMs: totalMs, CollectorsMs: copied})
+}
+
+// LastCollectionTimings returns timings from the most recent system
+// collection, or nil if none has run yet.
+func LastCollectionTimings() *CollectionTimings {
+	return lastTimings.Load()
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/devatlogstyx/probestyx/internal/config"
+)
+
+// HTTP clients for sources with custom TLS settings, keyed by those settings
+// so scrapers sharing a certificate also share connections
+var (
+	tlsClients   = make(map[string]*http.Client)
+	tlsClientsMu sync.Mutex
+)
+
+func hasTLSConfig(source config.SourceConfig) bool {
+	return source.ClientCert != "" || source.ClientKey != "" || source.CACert != "" || source.InsecureSkipVerify
+}
+
+// clientFor returns the HTTP client to use for a source: the shared client
+// unless the source configures client certificates, a CA or skips verification.
+func clientFor(source config.SourceConfig) (*http.Client, error) {
+	if !hasTLSConfig(source) {
+		return getHTTPClient(), nil
+	}
+
+	key := tlsClientKey(source)
+
+	tlsClientsMu.Lock()
+	defer tlsClientsMu.Unlock()
+
+	if client, ok := tlsClients[key]; ok {
+		return client, nil
+	}
+
+	tlsConfig, err := buildTLSConfig(source)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	tlsClients[key] = client
+	return client, nil
+}
+
+func buildTLSConfig(source config.SourceConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: source.InsecureSkipVerify,
+	}
+
+	if source.ClientCert != "" || source.ClientKey != "" {
+		if source.ClientCert == "" || source.ClientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(source.ClientCert, source.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if source.CACert != "" {
+		pem, err := os.ReadFile(source.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", source.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// warnInsecureScrapers logs every scraper that skips certificate
+// verification so a dev-only setting can't quietly reach production
+func warnInsecureScrapers(scrapers []config.ScraperConfig) {
+	for _, scraper := range scrapers {
+		for _, sub := range expandSources(scraper) {
+			if sub.Source.InsecureSkipVerify {
+				log.Printf("WARN: Scraper %s: TLS certificate verification is DISABLED (insecure_skip_verify) for %s - do not use in production", scraper.Name, sub.Source.URL)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/devatlogstyx/probestyx/internal/utils"
+)
+
+// sendSize sends a byte count under name, whose suffix (_mb, _gb) gives
+// its unit, with kv as labels. When byte_unit names another unit the value
+// is also sent converted under the name with that unit's suffix, so
+// existing keys keep their meaning and the configured unit sits alongside.
+func sendSize(send func(string, interface{}), name string, bytes uint64, kv ...string) {
+	base, unit := name, "bytes"
+	if idx := strings.LastIndex(name, "_"); idx != -1 && utils.IsByteUnit(name[idx+1:]) {
+		base, unit = name[:idx], name[idx+1:]
+	}
+
+	send(utils.Labeled(name, kv...), round(utils.ConvertBytes(float64(bytes), unit)))
+	if byteUnit != "" && byteUnit != unit {
+		send(utils.Labeled(base+"_"+byteUnit, kv...), round(utils.ConvertBytes(float64(bytes), byteUnit)))
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSendSizeKeepsOriginalKeys(t *testing.T) {
+	defer func(old string) { byteUnit = old }(byteUnit)
+	defer func(old int) { precision = old }(precision)
+	precision = 2
+
+	collect := func() map[string]interface{} {
+		got := make(map[string]interface{})
+		send := func(key string, value interface{}) { got[key] = value }
+		sendSize(send, "total_ram_mb", 2<<30)
+		sendSize(send, "total_disk_gb", 2<<30, "mount", "/data")
+		return got
+	}
+
+	byteUnit = ""
+	want := map[string]interface{}{
+		"total_ram_mb":                 float64(2048),
+		`total_disk_gb{mount="/data"}`: float64(2),
+	}
+	if got := collect(); !reflect.DeepEqual(got, want) {
+		t.Errorf("without byte_unit: %v, want %v", got, want)
+	}
+
+	byteUnit = "gb"
+	want["total_ram_gb"] = float64(2)
+	if got := collect(); !reflect.DeepEqual(got, want) {
+		t.Errorf("byte_unit gb: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Minimum time between webhook notifications for one scraper, so a flapping
+// source can't flood the receiver
+const webhookCooldown = time.Minute
+
+// webhookPayload is POSTed to on_failure_webhook
+type webhookPayload struct {
+	Scraper   string `json:"scraper"`
+	Status    string `json:"status"` // "failure" or "recovered"
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// notifyWebhook fires the scraper's webhook when its down status differs
+// from what was last reported. Callers must hold scraperStatesMu.
+func notifyWebhook(name, webhook string, state *scraperState, now time.Time) {
+	if webhook == "" || state.down == state.notifiedDown || now.Sub(state.lastNotified) < webhookCooldown {
+		return
+	}
+	state.notifiedDown = state.down
+	state.lastNotified = now
+
+	payload := webhookPayload{
+		Scraper:   name,
+		Status:    "recovered",
+		Timestamp: now.Unix(),
+	}
+	if state.down {
+		payload.Status = "failure"
+		payload.Error = state.lastError
+	}
+
+	goBackground(func(ctx context.Context) {
+		sendWebhook(ctx, webhook, payload)
+	})
+}
+
+func sendWebhook(ctx context.Context, url string, payload webhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Webhook for %s: %v", payload.Scraper, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		log.Printf("Webhook for %s failed: %v", payload.Scraper, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("Webhook for %s returned %s", payload.Scraper, resp.Status)
+	}
+}
@@ -2,11 +2,15 @@ package parsers
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/devatlogstyx/probestyx/internal/config"
+	"github.com/devatlogstyx/probestyx/internal/utils"
 )
 
 func ParseJSON(data string) (map[string]interface{}, error) {
@@ -15,6 +19,119 @@ func ParseJSON(data string) (map[string]interface{}, error) {
 	return result, err
 }
 
+// ParseJSONNumbers is ParseJSON with numbers kept as json.Number, so
+// integers beyond 2^53 (such as 64-bit byte counters) keep every digit
+// until something needs them as a float64
+func ParseJSONNumbers(data string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	var result map[string]interface{}
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ParseNDJSON parses newline-delimited JSON, one object per line. Objects
+// are merged in order, later lines overwriting earlier keys, unless keyField
+// is set: then each object is stored under the value of that field.
+func ParseNDJSON(data, keyField string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &object); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		if keyField == "" {
+			for key, value := range object {
+				result[key] = value
+			}
+			continue
+		}
+
+		key, ok := object[keyField]
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing key field %q", i+1, keyField)
+		}
+		result[fmt.Sprint(key)] = object
+	}
+	return result, nil
+}
+
+// ParseExpvar parses Go expvar output (/debug/vars) and flattens nested
+// objects into dotted keys such as "memstats.Alloc"
+func ParseExpvar(data string) (map[string]interface{}, error) {
+	parsed, err := ParseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return FlattenObjects(parsed, ""), nil
+}
+
+// FlattenObjects turns nested JSON objects into dotted keys. Arrays are
+// left as values.
+func FlattenObjects(data map[string]interface{}, prefix string) map[string]interface{} {
+	flat := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			for k, v := range FlattenObjects(nested, key) {
+				flat[k] = v
+			}
+			continue
+		}
+		flat[key] = value
+	}
+	return flat
+}
+
+// Matches a Prometheus sample line: name{labels} value
+var promSampleLine = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{[^}]*\})?\s+\S+`)
+
+// DetectFormat picks a parser for format: auto. A JSON or Prometheus
+// Content-Type wins; otherwise the body is sniffed: a leading "{" or "["
+// means json (ndjson when only the first line is a complete document), a
+// leading "#" or a "name value" first line means prometheus, and anything
+// else falls back to raw.
+func DetectFormat(contentType, data string) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "ndjson"), strings.Contains(contentType, "jsonl"):
+		return "ndjson"
+	case strings.Contains(contentType, "json"):
+		return "json"
+	case strings.Contains(contentType, "openmetrics"), strings.Contains(contentType, "version=0.0.4"):
+		return "prometheus"
+	}
+
+	trimmed := strings.TrimSpace(data)
+	switch {
+	case strings.HasPrefix(trimmed, "{"), strings.HasPrefix(trimmed, "["):
+		if firstLine, rest, multiline := strings.Cut(trimmed, "\n"); multiline && strings.TrimSpace(rest) != "" &&
+			json.Valid([]byte(firstLine)) && !json.Valid([]byte(trimmed)) {
+			return "ndjson"
+		}
+		return "json"
+	case strings.HasPrefix(trimmed, "#"):
+		return "prometheus"
+	}
+
+	firstLine, _, _ := strings.Cut(trimmed, "\n")
+	if promSampleLine.MatchString(firstLine) {
+		return "prometheus"
+	}
+	return "raw"
+}
+
 func ParsePrometheus(data string) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	lines := strings.Split(data, "\n")
@@ -45,9 +162,50 @@ func ParsePrometheus(data string) (map[string]interface{}, error) {
 	return result, nil
 }
 
-func ParseRaw(data string, pattern string) (map[string]interface{}, error) {
+// PromMetadata is the # HELP text and # TYPE of a Prometheus metric family
+type PromMetadata struct {
+	Help string
+	Type string
+}
+
+var promHelpUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+
+// ParsePrometheusMetadata collects the # HELP and # TYPE comments of a
+// Prometheus exposition, keyed by metric family name
+func ParsePrometheusMetadata(data string) map[string]PromMetadata {
+	result := make(map[string]PromMetadata)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		name, text := fields[1], strings.TrimSpace(fields[2])
+		meta := result[name]
+		switch fields[0] {
+		case "HELP":
+			meta.Help = promHelpUnescaper.Replace(text)
+		case "TYPE":
+			meta.Type = text
+		default:
+			continue
+		}
+		result[name] = meta
+	}
+	return result
+}
+
+// ParseRaw extracts key/value pairs with a regex. By default group 1 is
+// the key and group 2 the value; key_group, value_group and label_group
+// in the source reassign them, and a label group adds its capture as a
+// label on the key (e.g. `latency{unit="ms"}`).
+func ParseRaw(data string, source config.SourceConfig) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
+	pattern := source.Pattern
 	if pattern == "" {
 		pattern = `(\w+)=(\S+)`
 	}
@@ -57,17 +215,73 @@ func ParseRaw(data string, pattern string) (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	matches := re.FindAllStringSubmatch(data, -1)
-	for _, match := range matches {
-		if len(match) >= 3 {
-			key := match[1]
-			value := match[2]
+	keyGroup, valueGroup := source.KeyGroup, source.ValueGroup
+	if keyGroup == 0 {
+		keyGroup = 1
+	}
+	if valueGroup == 0 {
+		valueGroup = 2
+	}
+	labelName := source.LabelName
+	if labelName == "" {
+		labelName = "label"
+	}
+	for _, group := range []int{keyGroup, valueGroup, source.LabelGroup} {
+		if group < 0 || group > re.NumSubexp() {
+			return nil, fmt.Errorf("capture group %d out of range, pattern has %d", group, re.NumSubexp())
+		}
+	}
 
-			// Try to parse as number
-			if numVal, err := strconv.ParseFloat(value, 64); err == nil {
-				result[key] = numVal
-			} else {
-				result[key] = value
+	// In debug mode, track which lines produced values so the rest can be
+	// reported
+	var lineStarts []int
+	var matchedLines map[int]bool
+	if source.Debug {
+		lineStarts = lineOffsets(data)
+		matchedLines = make(map[int]bool)
+	}
+
+	for _, loc := range re.FindAllStringSubmatchIndex(data, -1) {
+		match := make([]string, len(loc)/2)
+		for i := range match {
+			if loc[2*i] >= 0 {
+				match[i] = data[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		key := match[keyGroup]
+		value := match[valueGroup]
+
+		var labels []string
+		if source.LabelGroup > 0 {
+			labels = append(labels, labelName, match[source.LabelGroup])
+		}
+		if source.Debug {
+			first, last := lineAt(lineStarts, loc[0]), lineAt(lineStarts, max(loc[0], loc[1]-1))
+			for line := first; line <= last; line++ {
+				matchedLines[line] = true
+			}
+			log.Printf("raw debug: line %d: %s = %q", first, key, value)
+			labels = append(labels, "line", strconv.Itoa(first))
+		}
+		key = utils.Labeled(key, labels...)
+
+		// Try to parse as number
+		if numVal, err := strconv.ParseFloat(value, 64); err == nil {
+			result[key] = numVal
+		} else {
+			result[key] = value
+		}
+	}
+
+	if source.Debug {
+		for i, start := range lineStarts {
+			end := len(data)
+			if i+1 < len(lineStarts) {
+				end = lineStarts[i+1]
+			}
+			line := strings.TrimRight(data[start:end], "\r\n")
+			if !matchedLines[i+1] && strings.TrimSpace(line) != "" {
+				log.Printf("raw debug: line %d unmatched: %q", i+1, line)
 			}
 		}
 	}
@@ -75,6 +289,22 @@ func ParseRaw(data string, pattern string) (map[string]interface{}, error) {
 	return result, nil
 }
 
+// lineOffsets returns the byte offset at which each line of data starts
+func lineOffsets(data string) []int {
+	starts := []int{0}
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\n' && i+1 < len(data) {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// lineAt returns the 1-based line containing byte offset
+func lineAt(starts []int, offset int) int {
+	return sort.Search(len(starts), func(i int) bool { return starts[i] > offset })
+}
+
 func ApplyFilters(data map[string]interface{}, filter *config.FilterConfig) map[string]interface{} {
 	result := make(map[string]interface{})
 
@@ -99,10 +329,37 @@ func ApplyFilters(data map[string]interface{}, filter *config.FilterConfig) map[
 			}
 		}
 
-		if !exclude {
-			result[key] = value
+		if exclude {
+			continue
 		}
+
+		if !InRange(value, filter.MinValue, filter.MaxValue) {
+			continue
+		}
+
+		result[key] = value
 	}
 
 	return result
+}
+
+// InRange reports whether a numeric value lies within [min, max]. Either
+// bound may be nil. Non-numeric values are always considered in range.
+func InRange(value interface{}, min, max *float64) bool {
+	if min == nil && max == nil {
+		return true
+	}
+
+	if !utils.IsNumber(value) {
+		return true
+	}
+	num, _ := utils.ToFloat64(value)
+
+	if min != nil && num < *min {
+		return false
+	}
+	if max != nil && num > *max {
+		return false
+	}
+	return true
 }
\ No newline at end of file
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitWords breaks a name into words at separators (anything that isn't a
+// letter or digit), lower-to-upper transitions ("bytesSent") and the end of
+// an acronym ("HTTPRequest" -> "HTTP", "Request"). Digits stay attached to
+// the preceding word ("md5Sum" -> "md5", "Sum").
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	var current []rune
+
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = nil
+		}
+	}
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return words
+}
+
+// ToSnakeCase converts a name to snake_case: "HTTPRequestCount" and
+// "http-request.count" both become "http_request_count"
+func ToSnakeCase(name string) string {
+	words := splitWords(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
+
+// ToCamelCase converts a name to camelCase: "http_request_count" and
+// "HTTPRequestCount" both become "httpRequestCount"
+func ToCamelCase(name string) string {
+	words := splitWords(name)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if i > 0 {
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			word = string(runes)
+		}
+		words[i] = word
+	}
+	return strings.Join(words, "")
+}
+
+// ApplyNameStyle converts name to the given style: "snake", "camel" or
+// "lower". Any other style, including "as-is", returns name unchanged.
+func ApplyNameStyle(name, style string) string {
+	switch style {
+	case "snake":
+		return ToSnakeCase(name)
+	case "camel":
+		return ToCamelCase(name)
+	case "lower":
+		return strings.ToLower(name)
+	}
+	return name
+}
@@ -0,0 +1,54 @@
+package utils
+
+import "testing"
+
+func TestToSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"HTTPRequests", "http_requests"},
+		{"userID", "user_id"},
+		{"HTTPRequestCount", "http_request_count"},
+		{"getHTTPResponseCode", "get_http_response_code"},
+		{"bytesSent", "bytes_sent"},
+		{"md5Sum", "md5_sum"},
+		{"http-request.count", "http_request_count"},
+		{"already_snake", "already_snake"},
+		{"Mixed_caseName", "mixed_case_name"},
+		{"__padded__", "padded"},
+		{"ID", "id"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ToSnakeCase(tt.name); got != tt.want {
+			t.Errorf("ToSnakeCase(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToCamelCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"http_request_count", "httpRequestCount"},
+		{"HTTPRequests", "httpRequests"},
+		{"userID", "userId"},
+		{"user_id", "userId"},
+		{"HTTPRequestCount", "httpRequestCount"},
+		{"alreadyCamel", "alreadyCamel"},
+		{"Mixed_caseName", "mixedCaseName"},
+		{"md5_sum", "md5Sum"},
+		{"http-request.count", "httpRequestCount"},
+		{"ID", "id"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ToCamelCase(tt.name); got != tt.want {
+			t.Errorf("ToCamelCase(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Evaluate computes an arithmetic/boolean expression over named variables.
+//
+// Supported: numbers, identifiers, parentheses, + - * / %, comparisons
+// (< <= > >= == !=), logical && || and unary ! / -. Booleans are returned
+// as 1 or 0, so "swap_used_mb > 0 && ram_usage_percent > 90" yields 1 or 0.
+func Evaluate(expr string, vars map[string]float64) (float64, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	p := &exprParser{tokens: tokens, vars: vars}
+	val, err := p.parseOr()
+	if err != nil {
+		return 0, err
+	}
+	if p.pos < len(p.tokens) {
+		return 0, fmt.Errorf("unexpected token %q", p.tokens[p.pos].text)
+	}
+	return val, nil
+}
+
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			text := string(runes[start:i])
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", text)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, num: num})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[start:i])})
+
+		default:
+			// Two-character operators first
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				switch two {
+				case "&&", "||", "<=", ">=", "==", "!=":
+					tokens = append(tokens, token{kind: tokOp, text: two})
+					i += 2
+					continue
+				}
+			}
+			if strings.ContainsRune("+-*/%()<>!", r) {
+				tokens = append(tokens, token{kind: tokOp, text: string(r)})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character %q", r)
+		}
+	}
+
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+	vars   map[string]float64
+}
+
+func (p *exprParser) peekOp(ops ...string) (string, bool) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (float64, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		if _, ok := p.peekOp("||"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return 0, err
+		}
+		left = boolToFloat(left != 0 || right != 0)
+	}
+}
+
+func (p *exprParser) parseAnd() (float64, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		if _, ok := p.peekOp("&&"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseComparison()
+		if err != nil {
+			return 0, err
+		}
+		left = boolToFloat(left != 0 && right != 0)
+	}
+}
+
+func (p *exprParser) parseComparison() (float64, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.peekOp("<", "<=", ">", ">=", "==", "!=")
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAdditive()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "<":
+			left = boolToFloat(left < right)
+		case "<=":
+			left = boolToFloat(left <= right)
+		case ">":
+			left = boolToFloat(left > right)
+		case ">=":
+			left = boolToFloat(left >= right)
+		case "==":
+			left = boolToFloat(left == right)
+		case "!=":
+			left = boolToFloat(left != right)
+		}
+	}
+}
+
+func (p *exprParser) parseAdditive() (float64, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.peekOp("+", "-")
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return 0, err
+		}
+		if op == "+" {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (p *exprParser) parseMultiplicative() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.peekOp("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "*":
+			left *= right
+		case "/":
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		case "%":
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left = float64(int64(left) % int64(right))
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (float64, error) {
+	if op, ok := p.peekOp("-", "!", "+"); ok {
+		p.pos++
+		val, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "-":
+			return -val, nil
+		case "!":
+			return boolToFloat(val == 0), nil
+		}
+		return val, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (float64, error) {
+	if p.pos >= len(p.tokens) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokNumber:
+		return tok.num, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return 1, nil
+		case "false":
+			return 0, nil
+		}
+		val, ok := p.vars[tok.text]
+		if !ok {
+			return 0, fmt.Errorf("unknown variable %q", tok.text)
+		}
+		return val, nil
+	}
+
+	if tok.text == "(" {
+		val, err := p.parseOr()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := p.peekOp(")"); !ok {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return val, nil
+	}
+
+	return 0, fmt.Errorf("unexpected token %q", tok.text)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]float64{"value": 5, "ram_usage_percent": 95, "swap_used_mb": 0, "disk.free": 10}
+
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		// Precedence and associativity
+		{"2 + 3 * 4", 14},
+		{"(2 + 3) * 4", 20},
+		{"10 - 4 - 3", 3},
+		{"100 / 10 / 5", 2},
+		{"2 * 3 % 4", 2},
+		{"7 % 3 + 1", 2},
+		{"1 + 2 < 4", 1},
+		{"1 < 2 == 1", 1},
+		{"1 || 0 && 0", 1},
+		{"(1 || 0) && 0", 0},
+
+		// Unary operators
+		{"-3", -3},
+		{"--3", 3},
+		{"-value * 2", -10},
+		{"2 * -value", -10},
+		{"-(2 + 3)", -5},
+		{"+4", 4},
+		{"!0", 1},
+		{"!value", 0},
+		{"10 - -2", 12},
+
+		// Numbers
+		{"1.5e3", 1500},
+		{"2.5E-1", 0.25},
+		{".5", 0.5},
+		{"value*8", 40},
+
+		// Comparisons and logic over variables
+		{"ram_usage_percent > 90", 1},
+		{"ram_usage_percent >= 95 && swap_used_mb > 0", 0},
+		{"ram_usage_percent > 90 || swap_used_mb > 0", 1},
+		{"value != 5", 0},
+		{"value <= 5", 1},
+		{"true && !false", 1},
+		{"disk.free * 2", 20},
+	}
+
+	for _, tt := range tests {
+		got, err := Evaluate(tt.expr, vars)
+		if err != nil {
+			t.Errorf("Evaluate(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	vars := map[string]float64{"value": 5}
+
+	tests := []struct {
+		expr string
+		want string // substring of the error
+	}{
+		{"value / 0", "division by zero"},
+		{"value % 0", "division by zero"},
+		{"value / (1 - 1)", "division by zero"},
+		{"missing * 2", `unknown variable "missing"`},
+		{"Value * 2", `unknown variable "Value"`},
+		{"", "unexpected end"},
+		{"value *", "unexpected end"},
+		{"(value + 1", "missing closing parenthesis"},
+		{"value + 1)", `unexpected token ")"`},
+		{"value 2", `unexpected token "2"`},
+		{"* 2", `unexpected token "*"`},
+		{"1.2.3", "invalid number"},
+		{"2e", "invalid number"},
+		{"value ^ 2", "unexpected character"},
+		{"value = 2", "unexpected character"},
+		{"value & 1", "unexpected character"},
+	}
+
+	for _, tt := range tests {
+		_, err := Evaluate(tt.expr, vars)
+		if err == nil {
+			t.Errorf("Evaluate(%q) succeeded, want error containing %q", tt.expr, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Evaluate(%q) error = %q, want it to contain %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+// legacyCalculate is Calculate as it was before the expression evaluator:
+// "value OP number" for a single *, /, + or -, anything else unchanged
+func legacyCalculate(value float64, expr string) float64 {
+	expr = strings.ReplaceAll(expr, "value", fmt.Sprintf("%f", value))
+	expr = strings.TrimSpace(expr)
+
+	for _, op := range []string{"*", "/", "+", "-"} {
+		if !strings.Contains(expr, op) {
+			continue
+		}
+		parts := strings.Split(expr, op)
+		if len(parts) == 2 {
+			if operand, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
+				switch op {
+				case "*":
+					return value * operand
+				case "/":
+					return value / operand
+				case "+":
+					return value + operand
+				case "-":
+					return value - operand
+				}
+			}
+		}
+		break
+	}
+	return value
+}
+
+func TestCalculateMatchesLegacyExpressions(t *testing.T) {
+	// The forms the old parser understood, over values it handled
+	exprs := []string{
+		"value * 8", "value*8", "value * 0.001", "value * 1e-3", "value * -2",
+		"value / 1024", "value/1048576", "value / 3",
+		"value + 273.15", "value+1",
+		"value - 32", "value-1",
+		"  value * 100  ",
+	}
+	values := []float64{0, 1, 0.5, 42, 1024, 123456789, 1e15}
+
+	for _, expr := range exprs {
+		for _, value := range values {
+			if got, want := Calculate(value, expr), legacyCalculate(value, expr); got != want {
+				t.Errorf("Calculate(%v, %q) = %v, was %v", value, expr, got, want)
+			}
+		}
+	}
+
+	// Negative values keep their results for every operator but -, which
+	// the old parser split on the value's own minus sign
+	for _, expr := range []string{"value * 8", "value / 4", "value + 1"} {
+		if got, want := Calculate(-42, expr), legacyCalculate(-42, expr); got != want {
+			t.Errorf("Calculate(-42, %q) = %v, was %v", expr, got, want)
+		}
+	}
+}
+
+func TestCalculateChangedResults(t *testing.T) {
+	// Expressions the old parser got wrong or ignored, and division by
+	// zero, which used to give Inf and now leaves the value unchanged
+	tests := []struct {
+		value  float64
+		expr   string
+		want   float64
+		legacy float64
+	}{
+		{-5, "value - 1", -6, -5},
+		{5, "8 * value", 40, 25},
+		{5, "value * 8 + 1", 41, 5},
+		{2048, "value / 1024 / 1024", 2.0 / 1024, 2048},
+		{5, "value / 0", 5, math.Inf(1)},
+		{5, "not an expression", 5, 5},
+	}
+
+	for _, tt := range tests {
+		if got := Calculate(tt.value, tt.expr); got != tt.want {
+			t.Errorf("Calculate(%v, %q) = %v, want %v", tt.value, tt.expr, got, tt.want)
+		}
+		if got := legacyCalculate(tt.value, tt.expr); got != tt.legacy {
+			t.Errorf("legacy Calculate(%v, %q) = %v, want %v", tt.value, tt.expr, got, tt.legacy)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "strconv"
+
+// Flatten turns nested maps into a single map with dotted keys, e.g.
+// {"system": {"cpu_count": 8}} becomes {"system.cpu_count": 8}. Array
+// elements get their index as the last key segment. Timestamp-wrapped
+// values ({"value": X, "timestamp": T}) are kept whole as leaves.
+func Flatten(m map[string]interface{}, prefix string) map[string]interface{} {
+	flat := make(map[string]interface{}, len(m))
+	flattenInto(flat, m, prefix)
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, value interface{}, key string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, ok := v["value"]; ok && len(v) == 2 {
+			if _, ok := v["timestamp"]; ok {
+				flat[key] = v
+				return
+			}
+		}
+		for k, inner := range v {
+			flattenInto(flat, inner, joinKey(key, k))
+		}
+	case []float64:
+		for i, inner := range v {
+			flat[joinKey(key, strconv.Itoa(i))] = inner
+		}
+	case []interface{}:
+		for i, inner := range v {
+			flattenInto(flat, inner, joinKey(key, strconv.Itoa(i)))
+		}
+	default:
+		flat[key] = v
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Labeled builds a Prometheus-style series key such as
+// `disk_usage_percent{mount="/data"}` from a name and label key/value pairs.
+func Labeled(name string, kv ...string) string {
+	if len(kv) < 2 {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(kv[i])
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(kv[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseLabeled splits a series key built by Labeled back into its name and
+// label key/value pairs. Keys without labels return the key as the name.
+func ParseLabeled(key string) (string, []string) {
+	open := strings.IndexByte(key, '{')
+	if open < 0 || !strings.HasSuffix(key, "}") {
+		return key, nil
+	}
+
+	name := key[:open]
+	rest := key[open+1 : len(key)-1]
+	var kv []string
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return key, nil
+		}
+		quoted, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			return key, nil
+		}
+		value, _ := strconv.Unquote(quoted)
+		kv = append(kv, rest[:eq], value)
+		rest = strings.TrimPrefix(rest[eq+1+len(quoted):], ",")
+	}
+	return name, kv
+}
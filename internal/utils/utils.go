@@ -1,16 +1,52 @@
 package utils
 
 import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// GetJSONPath resolves a dotted path. Numeric segments index into arrays,
+// and a "*" segment fans out over every element of an array or object,
+// returning the matches as a []interface{}.
 func GetJSONPath(data map[string]interface{}, path string) (interface{}, bool) {
-	parts := strings.Split(path, ".")
-	var current interface{} = data
+	return getPath(data, strings.Split(path, "."))
+}
+
+func getPath(current interface{}, parts []string) (interface{}, bool) {
+	for i, part := range parts {
+		if part == "*" {
+			var children []interface{}
+			switch v := current.(type) {
+			case map[string]interface{}:
+				keys := make([]string, 0, len(v))
+				for key := range v {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					children = append(children, v[key])
+				}
+			case []interface{}:
+				children = v
+			default:
+				return nil, false
+			}
+
+			matches := make([]interface{}, 0, len(children))
+			for _, child := range children {
+				if value, ok := getPath(child, parts[i+1:]); ok {
+					matches = append(matches, value)
+				}
+			}
+			return matches, true
+		}
 
-	for _, part := range parts {
 		switch v := current.(type) {
 		case map[string]interface{}:
 			var ok bool
@@ -18,6 +54,12 @@ func GetJSONPath(data map[string]interface{}, path string) (interface{}, bool) {
 			if !ok {
 				return nil, false
 			}
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			current = v[idx]
 		default:
 			return nil, false
 		}
@@ -26,43 +68,65 @@ func GetJSONPath(data map[string]interface{}, path string) (interface{}, bool) {
 	return current, true
 }
 
-func Calculate(value float64, expr string) float64 {
-	// Simple expression parser for basic operations
-	expr = strings.ReplaceAll(expr, "value", fmt.Sprintf("%f", value))
-	expr = strings.TrimSpace(expr)
-
-	// Handle simple operations: value * X, value / X, value + X, value - X
-	if strings.Contains(expr, "*") {
-		parts := strings.Split(expr, "*")
-		if len(parts) == 2 {
-			if multiplier, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
-				return value * multiplier
+// PathMatch is one value found by a wildcard path, with the keys (or array
+// indexes) that each "*" segment matched
+type PathMatch struct {
+	Value    interface{}
+	Captures []string
+}
+
+// GetJSONPathMatches resolves a path like GetJSONPath but returns every
+// wildcard match separately along with the segments it matched.
+func GetJSONPathMatches(data map[string]interface{}, path string) []PathMatch {
+	var matches []PathMatch
+	collectMatches(data, strings.Split(path, "."), nil, &matches)
+	return matches
+}
+
+func collectMatches(current interface{}, parts []string, captures []string, matches *[]PathMatch) {
+	if len(parts) == 0 {
+		*matches = append(*matches, PathMatch{Value: current, Captures: captures})
+		return
+	}
+
+	part, rest := parts[0], parts[1:]
+	switch v := current.(type) {
+	case map[string]interface{}:
+		if part != "*" {
+			if child, ok := v[part]; ok {
+				collectMatches(child, rest, captures, matches)
 			}
+			return
 		}
-	} else if strings.Contains(expr, "/") {
-		parts := strings.Split(expr, "/")
-		if len(parts) == 2 {
-			if divisor, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
-				return value / divisor
-			}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
 		}
-	} else if strings.Contains(expr, "+") {
-		parts := strings.Split(expr, "+")
-		if len(parts) == 2 {
-			if addend, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
-				return value + addend
-			}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectMatches(v[key], rest, append(captures[:len(captures):len(captures)], key), matches)
 		}
-	} else if strings.Contains(expr, "-") {
-		parts := strings.Split(expr, "-")
-		if len(parts) == 2 {
-			if subtrahend, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
-				return value - subtrahend
+	case []interface{}:
+		if part != "*" {
+			if idx, err := strconv.Atoi(part); err == nil && idx >= 0 && idx < len(v) {
+				collectMatches(v[idx], rest, captures, matches)
 			}
+			return
+		}
+		for i, child := range v {
+			collectMatches(child, rest, append(captures[:len(captures):len(captures)], strconv.Itoa(i)), matches)
 		}
 	}
+}
 
-	return value
+func Calculate(value float64, expr string) float64 {
+	// Evaluate the expression with "value" bound to the metric value;
+	// anything unparseable leaves the value untouched
+	result, err := Evaluate(expr, map[string]float64{"value": value})
+	if err != nil {
+		return value
+	}
+	return result
 }
 
 func ToFloat64(v interface{}) (float64, bool) {
@@ -75,6 +139,16 @@ func ToFloat64(v interface{}) (float64, bool) {
 		return float64(val), true
 	case int64:
 		return float64(val), true
+	case int32:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case uint32:
+		return float64(val), true
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return f, true
+		}
 	case string:
 		if f, err := strconv.ParseFloat(val, 64); err == nil {
 			return f, true
@@ -83,10 +157,158 @@ func ToFloat64(v interface{}) (float64, bool) {
 	return 0, false
 }
 
+// Decode converts an encoded string value to a number. "hex" parses the
+// string as a hexadecimal integer (an optional 0x prefix is allowed).
+// "base64" decodes the string and then parses the result as a decimal
+// number, or as a big-endian unsigned integer of up to 8 bytes when the
+// decoded bytes are binary. "number" reads human-formatted numbers such
+// as "1,234" or "87%" by dropping thousands separators, a percent sign
+// and surrounding whitespace.
+func Decode(value interface{}, encoding string) (float64, error) {
+	// Sources that already parsed the value as a number need no cleanup
+	if _, isString := value.(string); !isString && encoding == "number" {
+		if f, ok := ToFloat64(value); ok {
+			return f, nil
+		}
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("decode %s: expected a string, got %T", encoding, value)
+	}
+	s = strings.TrimSpace(s)
+
+	switch encoding {
+	case "number":
+		cleaned := strings.TrimSpace(strings.TrimSuffix(strings.ReplaceAll(s, ",", ""), "%"))
+		f, err := strconv.ParseFloat(cleaned, 64)
+		if err != nil {
+			return 0, fmt.Errorf("decode number: %q is not a number", s)
+		}
+		return f, nil
+	case "hex":
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+		n, err := strconv.ParseUint(s, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("decode hex: %w", err)
+		}
+		return float64(n), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return 0, fmt.Errorf("decode base64: %w", err)
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64); err == nil {
+			return f, nil
+		}
+		if len(data) == 0 || len(data) > 8 {
+			return 0, fmt.Errorf("decode base64: %d bytes is neither a number nor an integer", len(data))
+		}
+		buf := make([]byte, 8)
+		copy(buf[8-len(data):], data)
+		return float64(binary.BigEndian.Uint64(buf)), nil
+	}
+	return 0, fmt.Errorf("unknown decode: %s", encoding)
+}
+
+// Divisors for the supported byte units
+var byteUnits = map[string]float64{
+	"bytes": 1,
+	"kb":    1024,
+	"mb":    1024 * 1024,
+	"gb":    1024 * 1024 * 1024,
+	"tb":    1024 * 1024 * 1024 * 1024,
+}
+
+// IsByteUnit reports whether unit is one of bytes, kb, mb, gb or tb
+func IsByteUnit(unit string) bool {
+	_, ok := byteUnits[unit]
+	return ok
+}
+
+// ConvertBytes converts a byte count to the given unit (bytes, kb, mb, gb,
+// tb). Unknown units return the value unchanged.
+func ConvertBytes(value float64, unit string) float64 {
+	if divisor, ok := byteUnits[unit]; ok {
+		return value / divisor
+	}
+	return value
+}
+
+// IsNumber reports whether v holds a numeric type (strings don't count)
+func IsNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, float32, int, int64, int32, uint64, uint32, json.Number:
+		return true
+	}
+	return false
+}
+
+// Round rounds val to precision decimal places. math.Round keeps large
+// counters intact where an int conversion would overflow.
 func Round(val float64, precision int) float64 {
-	ratio := float64(1)
-	for i := 0; i < precision; i++ {
-		ratio *= 10
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return val
+	}
+	ratio := math.Pow10(precision)
+	rounded := math.Round(val*ratio) / ratio
+	if math.IsInf(rounded, 0) || math.IsNaN(rounded) {
+		// val*ratio overflowed; val already has fewer significant digits than requested
+		return val
+	}
+	return rounded
+}
+
+// Summarize reduces a set of samples to one statistic: "mean", "min",
+// "max", "sum", "count" or a percentile such as "p50", "p95", "p99".
+func Summarize(values []float64, stat string) (float64, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	switch stat {
+	case "count":
+		return float64(len(values)), true
+	case "sum", "mean":
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if stat == "mean" {
+			return sum / float64(len(values)), true
+		}
+		return sum, true
+	case "min", "max":
+		result := values[0]
+		for _, v := range values[1:] {
+			if (stat == "min" && v < result) || (stat == "max" && v > result) {
+				result = v
+			}
+		}
+		return result, true
+	}
+
+	if strings.HasPrefix(stat, "p") {
+		p, err := strconv.ParseFloat(stat[1:], 64)
+		if err != nil || p < 0 || p > 100 {
+			return 0, false
+		}
+		return percentile(values, p), true
 	}
-	return float64(int(val*ratio+0.5)) / ratio
-}
\ No newline at end of file
+
+	return 0, false
+}
+
+// percentile uses linear interpolation between closest ranks
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		name      string
+		val       float64
+		precision int
+		want      float64
+	}{
+		{"two places", 1234.5678, 2, 1234.57},
+		{"negative", -1234.5678, 2, -1234.57},
+		{"negative half away from zero", -2.5, 0, -3},
+		{"negative to zero", -0.004, 2, 0},
+		{"byte counter", 47244640256, 2, 47244640256},
+		{"large magnitude", 1e15, 2, 1e15},
+		{"large negative magnitude", -1e15, 2, -1e15},
+		{"large with fraction", 1e15 + 0.5, 0, 1e15 + 1},
+		{"high precision", 0.123456789, 8, 0.12345679},
+		{"negative precision", 1234.5, -2, 1200},
+		// val*ratio overflows to Inf; val comes back unchanged
+		{"overflow", 1e300, 10, 1e300},
+		{"negative overflow", -1e300, 10, -1e300},
+		{"max float", math.MaxFloat64, 2, math.MaxFloat64},
+		// Pow10 itself returns Inf, making val*ratio/ratio NaN
+		{"pow10 overflow", 1.5, 400, 1.5},
+		{"infinity", math.Inf(1), 2, math.Inf(1)},
+		{"negative infinity", math.Inf(-1), 2, math.Inf(-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Round(tt.val, tt.precision); got != tt.want {
+				t.Errorf("Round(%v, %d) = %v, want %v", tt.val, tt.precision, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundNaN(t *testing.T) {
+	if got := Round(math.NaN(), 2); !math.IsNaN(got) {
+		t.Errorf("Round(NaN, 2) = %v, want NaN", got)
+	}
+}